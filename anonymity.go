@@ -0,0 +1,203 @@
+package groxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// AnonymityLevel describes how much a proxy reveals about the client using it
+type AnonymityLevel int
+
+const (
+	// AnonymityUnknown is the level of proxies which have not been checked against a judge
+	AnonymityUnknown AnonymityLevel = iota
+	// Transparent proxies forward the real ip address of the client
+	Transparent
+	// Anonymous proxies hide the client ip address but reveal that a proxy is being used
+	Anonymous
+	// Elite proxies hide the client ip address and send no headers revealing that a proxy is being used
+	Elite
+)
+
+// String returns the name of the anonymity level
+func (a AnonymityLevel) String() string {
+	switch a {
+	case Transparent:
+		return "transparent"
+	case Anonymous:
+		return "anonymous"
+	case Elite:
+		return "elite"
+	}
+	return "unknown"
+}
+
+// ParseAnonymityLevel returns the anonymity level named by s, unknown names return AnonymityUnknown
+// Besides the names returned by String it understands the single letter codes used by proxy lists, N, A and H, and
+// names followed by proxy such as "elite proxy"
+func ParseAnonymityLevel(s string) AnonymityLevel {
+	switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), " proxy") {
+	case "transparent", "n", "noa", "none":
+		return Transparent
+	case "anonymous", "a", "anm", "anon":
+		return Anonymous
+	case "elite", "h", "hia", "high", "high_anonymous", "high anonymity":
+		return Elite
+	}
+	return AnonymityUnknown
+}
+
+// proxyHeaders are request headers added by proxies which reveal that a proxy is being used
+var proxyHeaders = []string{
+	"Via",
+	"Forwarded",
+	"Forwarded-For",
+	"X-Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Real-Ip",
+	"X-Client-Ip",
+	"Client-Ip",
+	"X-Cluster-Client-Ip",
+	"X-Originating-Ip",
+	"True-Client-Ip",
+	"X-Proxy-Id",
+	"Proxy-Connection",
+	"X-Proxy-Connection",
+	"X-Bluecoat-Via",
+}
+
+// JudgeResponse is what a proxy judge saw of a request, the client address it connected from and the request headers
+type JudgeResponse struct {
+	Origin  string
+	Headers http.Header
+}
+
+// ParseJudgeResponse parses the body returned by a proxy judge
+// JSON bodies with headers and origin or ip fields are understood, which covers httpbin.org/get and the groxy Judge,
+// otherwise the body is read as the "HTTP_X_FORWARDED_FOR = value" or "Name: value" lines printed by azenv style judges
+func ParseJudgeResponse(body string) JudgeResponse {
+	resp := JudgeResponse{Headers: make(http.Header)}
+	var v struct {
+		Origin  string                 `json:"origin"`
+		IP      string                 `json:"ip"`
+		Headers map[string]interface{} `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(body), &v); err == nil {
+		resp.Origin = v.Origin
+		if resp.Origin == "" {
+			resp.Origin = v.IP
+		}
+		for name, value := range v.Headers {
+			switch value := value.(type) {
+			case string:
+				resp.Headers.Add(name, value)
+			case []interface{}:
+				for _, item := range value {
+					if s, ok := item.(string); ok {
+						resp.Headers.Add(name, s)
+					}
+				}
+			}
+		}
+		return resp
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			continue
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if name == "REMOTE_ADDR" {
+			resp.Origin = value
+			continue
+		}
+		if strings.HasPrefix(name, "HTTP_") {
+			name = strings.Replace(strings.TrimPrefix(name, "HTTP_"), "_", "-", -1)
+		}
+		if strings.ContainsAny(name, " \t<>") {
+			continue
+		}
+		resp.Headers.Add(name, value)
+	}
+	return resp
+}
+
+// containsIP returns whether any of the addresses listed in value is one of ips, value is split on commas, semicolons
+// and whitespace so lists such as X-Forwarded-For and Forwarded, or Via with ports, are understood
+func containsIP(value string, ips []net.IP) bool {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	for _, field := range fields {
+		ip := fieldIP(field)
+		if ip == nil {
+			continue
+		}
+		for _, want := range ips {
+			if ip.Equal(want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldIP parses an address listed in a header such as 1.2.3.4, 1.2.3.4:80, for="[2001:db8::1]:80", or returns nil
+func fieldIP(field string) net.IP {
+	if i := strings.LastIndex(field, "="); i >= 0 {
+		field = field[i+1:]
+	}
+	field = strings.Trim(field, `"`)
+	if ip := net.ParseIP(field); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(field); err == nil {
+		field = host
+	}
+	return net.ParseIP(strings.Trim(field, "[]"))
+}
+
+// LeakedHeaders returns the names of the headers seen by the judge which reveal that a proxy was used
+func (r JudgeResponse) LeakedHeaders() []string {
+	var leaked []string
+	for _, name := range proxyHeaders {
+		if _, ok := r.Headers[name]; ok {
+			leaked = append(leaked, name)
+		}
+	}
+	return leaked
+}
+
+// Anonymity classifies the proxy which relayed the request seen by the judge, realIPs are the addresses of the client
+// A proxy is transparent when any of the real addresses reaches the judge, anonymous when it adds headers revealing a
+// proxy, and elite otherwise. Addresses are compared as ips, so 1.2.3.4 does not match 11.2.3.45
+func (r JudgeResponse) Anonymity(realIPs []string) AnonymityLevel {
+	var ips []net.IP
+	for _, ip := range realIPs {
+		if parsed := net.ParseIP(strings.TrimSpace(ip)); parsed != nil {
+			ips = append(ips, parsed)
+		}
+	}
+	if containsIP(r.Origin, ips) {
+		return Transparent
+	}
+	for _, values := range r.Headers {
+		for _, value := range values {
+			if containsIP(value, ips) {
+				return Transparent
+			}
+		}
+	}
+	if len(r.LeakedHeaders()) > 0 || strings.Contains(r.Origin, ",") {
+		return Anonymous
+	}
+	return Elite
+}
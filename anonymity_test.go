@@ -0,0 +1,65 @@
+package groxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJudgeResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantOrigin string
+		wantHeader map[string]string
+	}{
+		{"httpbin json", `{"origin": "5.6.7.8", "headers": {"Via": "1.1 squid", "Host": "httpbin.org"}}`, "5.6.7.8",
+			map[string]string{"Via": "1.1 squid", "Host": "httpbin.org"}},
+		{"json header lists", `{"ip": "5.6.7.8", "headers": {"X-Forwarded-For": ["1.2.3.4"]}}`, "5.6.7.8",
+			map[string]string{"X-Forwarded-For": "1.2.3.4"}},
+		{"azenv lines", "REMOTE_ADDR = 5.6.7.8\nHTTP_X_FORWARDED_FOR = 1.2.3.4\nHTTP_HOST = judge", "5.6.7.8",
+			map[string]string{"X-Forwarded-For": "1.2.3.4", "Host": "judge"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseJudgeResponse(tt.body)
+			if got.Origin != tt.wantOrigin {
+				t.Errorf("ParseJudgeResponse() origin = %v, want %v", got.Origin, tt.wantOrigin)
+			}
+			headers := make(map[string]string)
+			for name := range got.Headers {
+				headers[name] = got.Headers.Get(name)
+			}
+			if !reflect.DeepEqual(headers, tt.wantHeader) {
+				t.Errorf("ParseJudgeResponse() headers = %v, want %v", headers, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestJudgeResponse_Anonymity(t *testing.T) {
+	realIPs := []string{"1.2.3.4"}
+	tests := []struct {
+		name string
+		body string
+		want AnonymityLevel
+	}{
+		{"real ip in origin", `{"origin": "1.2.3.4"}`, Transparent},
+		{"real ip forwarded", `{"origin": "5.6.7.8", "headers": {"X-Forwarded-For": "1.2.3.4"}}`, Transparent},
+		{"real ip in forwarded list", `{"origin": "5.6.7.8", "headers": {"X-Forwarded-For": "9.9.9.9,1.2.3.4"}}`,
+			Transparent},
+		{"real ip with port", `{"origin": "5.6.7.8", "headers": {"Forwarded": "for=\"1.2.3.4:5000\";proto=http"}}`,
+			Transparent},
+		{"ip containing real ip", `{"origin": "11.2.3.45", "headers": {"Host": "judge"}}`, Elite},
+		{"header containing real ip", `{"origin": "5.6.7.8", "headers": {"X-Real-Ip": "1.2.3.40"}}`, Anonymous},
+		{"via header", `{"origin": "5.6.7.8", "headers": {"Via": "1.1 squid"}}`, Anonymous},
+		{"forwarded chain", `{"origin": "9.9.9.9, 5.6.7.8"}`, Anonymous},
+		{"no proxy headers", `{"origin": "5.6.7.8", "headers": {"Host": "judge"}}`, Elite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseJudgeResponse(tt.body).Anonymity(realIPs); got != tt.want {
+				t.Errorf("JudgeResponse.Anonymity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
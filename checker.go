@@ -0,0 +1,277 @@
+package groxy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxCheckBody is the number of bytes of a response body read by the checkers
+const maxCheckBody = 1 << 20
+
+// Checker verifies one property of a proxy, a Manager runs its checkers on every proxy, see WithCheckers. Check returns
+// nil when the proxy passes, ctx carries the deadline of the check
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, proxy *Proxy) error
+}
+
+// CheckOutcome is the result of running a single Checker on a proxy
+type CheckOutcome struct {
+	Name     string
+	Passed   bool
+	Duration time.Duration
+	Err      error
+	// Target is the url or address the checker first sent a request to through the proxy, empty when it sent none
+	Target string
+	// Timing is the breakdown of the first http request the checker sent through the proxy, zero when it sent none
+	Timing Timing
+}
+
+// checkTrace records the first request sent by a checker, runCheckers passes one to each checker in its context
+type checkTrace struct {
+	target string
+	timing Timing
+}
+
+type checkTraceKey struct{}
+
+// traceRequest records a request to target on the check trace of ctx, unless the checker already sent one
+func traceRequest(ctx context.Context, target string, timing Timing) {
+	if trace, ok := ctx.Value(checkTraceKey{}).(*checkTrace); ok && trace.target == "" {
+		trace.target, trace.timing = target, timing
+	}
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context, proxy *Proxy) error
+}
+
+// CheckerFunc returns a Checker named name which calls fn
+func CheckerFunc(name string, fn func(ctx context.Context, proxy *Proxy) error) Checker {
+	return checkerFunc{name: name, fn: fn}
+}
+
+func (c checkerFunc) Name() string {
+	return c.name
+}
+
+func (c checkerFunc) Check(ctx context.Context, proxy *Proxy) error {
+	return c.fn(ctx, proxy)
+}
+
+// proxyGet requests targetURL through proxy and returns the response with at most maxCheckBody bytes of its body
+func proxyGet(ctx context.Context, proxy *Proxy, targetURL string) (*http.Response, []byte, error) {
+	client := &http.Client{Transport: newTransport(proxy)}
+	req, err := http.NewRequest("GET", targetURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Close = true
+	tracer := newTracer()
+	resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(ctx, tracer.trace())))
+	traceRequest(ctx, targetURL, tracer.timing(time.Now()))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	return resp, body, err
+}
+
+// StatusCheck returns a checker which requests targetURL through the proxy and passes when the response status is one
+// of codes, or 200 when no codes are given
+func StatusCheck(targetURL string, codes ...int) Checker {
+	if len(codes) == 0 {
+		codes = []int{http.StatusOK}
+	}
+	return CheckerFunc("status", func(ctx context.Context, proxy *Proxy) error {
+		resp, _, err := proxyGet(ctx, proxy, targetURL)
+		if err != nil {
+			return requestFailure(targetURL, err)
+		}
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return nil
+			}
+		}
+		return statusFailure(targetURL, resp.StatusCode)
+	})
+}
+
+// LivenessCheck returns the checker a Manager runs unless WithCheckers replaces it, a StatusCheck of targetURL passing
+// on 200. An empty targetURL requests one of a list of well known pages picked at random on every check
+func LivenessCheck(targetURL string) Checker {
+	if targetURL != "" {
+		return StatusCheck(targetURL)
+	}
+	return CheckerFunc("status", func(ctx context.Context, proxy *Proxy) error {
+		return StatusCheck(randomTarget()).Check(ctx, proxy)
+	})
+}
+
+// BodyCheck returns a checker which requests targetURL through the proxy and passes when the body contains substr,
+// proxies injecting ads or serving captive portals fail it
+func BodyCheck(targetURL string, substr string) Checker {
+	return CheckerFunc("body", func(ctx context.Context, proxy *Proxy) error {
+		resp, body, err := proxyGet(ctx, proxy, targetURL)
+		if err != nil {
+			return requestFailure(targetURL, err)
+		}
+		if !strings.Contains(string(body), substr) {
+			return &CheckError{Kind: FailureContentMismatch, Target: targetURL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("body does not contain %q", substr)}
+		}
+		return nil
+	})
+}
+
+// BodyMatchCheck returns a checker which requests targetURL through the proxy and passes when the body matches pattern
+func BodyMatchCheck(targetURL string, pattern *regexp.Regexp) Checker {
+	return CheckerFunc("body-match", func(ctx context.Context, proxy *Proxy) error {
+		resp, body, err := proxyGet(ctx, proxy, targetURL)
+		if err != nil {
+			return requestFailure(targetURL, err)
+		}
+		if !pattern.Match(body) {
+			return &CheckError{Kind: FailureContentMismatch, Target: targetURL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("body does not match %s", pattern)}
+		}
+		return nil
+	})
+}
+
+// TLSCheck returns a checker which opens a tunnel to addr, a host:port, through the proxy and passes when a tls
+// handshake with a valid certificate for the host succeeds. Proxies which intercept tls fail it. config may be nil
+func TLSCheck(addr string, config *tls.Config) Checker {
+	return CheckerFunc("tls", func(ctx context.Context, proxy *Proxy) error {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		traceRequest(ctx, addr, Timing{})
+		conn, err := proxy.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return requestFailure(addr, err)
+		}
+		defer conn.Close()
+		cfg := &tls.Config{}
+		if config != nil {
+			cfg = config.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		if err := tls.Client(conn, cfg).HandshakeContext(ctx); err != nil {
+			kind := classifyError(err)
+			if kind != FailureTimeout && kind != FailureReset {
+				kind = FailureTLS
+			}
+			return &CheckError{Kind: kind, Target: addr, Err: err}
+		}
+		return nil
+	})
+}
+
+// HeaderLeakCheck returns a checker which requests a proxy judge through the proxy and fails when the judge sees any of
+// realIPs or a header revealing the proxy, see JudgeResponse
+func HeaderLeakCheck(judgeURL string, realIPs []string) Checker {
+	return CheckerFunc("header-leak", func(ctx context.Context, proxy *Proxy) error {
+		resp, body, err := proxyGet(ctx, proxy, judgeURL)
+		if err != nil {
+			return requestFailure(judgeURL, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return statusFailure(judgeURL, resp.StatusCode)
+		}
+		judged := ParseJudgeResponse(string(body))
+		if judged.Anonymity(realIPs) == Transparent {
+			return &CheckError{Kind: FailureAnonymityLeak, Target: judgeURL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("judge saw the real ip address")}
+		}
+		if leaked := judged.LeakedHeaders(); len(leaked) > 0 {
+			return &CheckError{Kind: FailureAnonymityLeak, Target: judgeURL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("judge saw headers %s", strings.Join(leaked, ", "))}
+		}
+		return nil
+	})
+}
+
+// DNSLeakCheck returns a checker which detects proxies whose dns lookups are made by the client's resolvers
+// urlTemplate is a url on a domain whose dns server logs lookups, its {token} placeholder is replaced with a random
+// token, for example http://{token}.leak.example.com/. After requesting it through the proxy, resolvers is called with
+// the token and returns the addresses of the resolvers which looked up the name. The proxy fails when any of them is
+// one of localResolvers. SOCKS4 proxies always fail since hostnames are resolved locally before connecting
+func DNSLeakCheck(urlTemplate string, resolvers func(ctx context.Context, token string) ([]string, error),
+	localResolvers []string) Checker {
+	return CheckerFunc("dns-leak", func(ctx context.Context, proxy *Proxy) error {
+		if proxy.Protocol() == SOCKS4 {
+			return &CheckError{Kind: FailureAnonymityLeak, Err: fmt.Errorf("socks4 proxies resolve hostnames locally")}
+		}
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		token := hex.EncodeToString(b)
+		leakURL := strings.Replace(urlTemplate, "{token}", token, -1)
+		if _, _, err := proxyGet(ctx, proxy, leakURL); err != nil {
+			return requestFailure(leakURL, err)
+		}
+		seen, err := resolvers(ctx, token)
+		if err != nil {
+			return err
+		}
+		for _, resolver := range seen {
+			for _, local := range localResolvers {
+				if resolver == local {
+					return &CheckError{Kind: FailureAnonymityLeak, Target: leakURL,
+						Err: fmt.Errorf("name was resolved by local resolver %s", resolver)}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// runCheckers runs checkers on proxy one after the other, each with its own timeout, and returns their outcomes
+// Checkers are not run once ctx is done, nor after the first failure when untilFailure is set
+func runCheckers(ctx context.Context, proxy *Proxy, checkers []Checker, timeout time.Duration,
+	untilFailure bool) []CheckOutcome {
+	var outcomes []CheckOutcome
+	for _, checker := range checkers {
+		if ctx.Err() != nil {
+			break
+		}
+		trace := &checkTrace{}
+		checkCtx, cancel := context.WithValue(ctx, checkTraceKey{}, trace), context.CancelFunc(func() {})
+		if timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(checkCtx, timeout)
+		}
+		t0 := time.Now()
+		err := checker.Check(checkCtx, proxy)
+		cancel()
+		outcomes = append(outcomes, CheckOutcome{
+			Name:     checker.Name(),
+			Passed:   err == nil,
+			Duration: time.Since(t0),
+			Err:      err,
+			Target:   trace.target,
+			Timing:   trace.timing,
+		})
+		if err != nil && untilFailure {
+			break
+		}
+	}
+	return outcomes
+}
@@ -0,0 +1,131 @@
+package groxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckers(t *testing.T) {
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<title>groxy test page</title>")
+	}))
+	defer site.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(secure.Certificate())
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+
+	tests := []struct {
+		name     string
+		checker  Checker
+		wantErr  bool
+		wantKind FailureKind
+	}{
+		{"status ok", StatusCheck(site.URL), false, 0},
+		{"status not found", StatusCheck(site.URL + "/missing"), true, FailureBadStatus},
+		{"status accepted code", StatusCheck(site.URL+"/missing", http.StatusNotFound), false, 0},
+		{"body contains", BodyCheck(site.URL, "groxy test page"), false, 0},
+		{"body missing", BodyCheck(site.URL, "captive portal"), true, FailureContentMismatch},
+		{"body matches", BodyMatchCheck(site.URL, regexp.MustCompile(`<title>groxy \w+`)), false, 0},
+		{"tls trusted", TLSCheck(strings.TrimPrefix(secure.URL, "https://"), &tls.Config{RootCAs: roots}), false, 0},
+		{"tls untrusted", TLSCheck(strings.TrimPrefix(secure.URL, "https://"), nil), true, FailureTLS},
+		{"no header leak", HeaderLeakCheck(judge.URL, []string{"10.9.9.9"}), false, 0},
+		{"real ip leaked", HeaderLeakCheck(judge.URL, []string{"127.0.0.1"}), true, FailureAnonymityLeak},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := tt.checker.Check(ctx, proxy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s.Check() error = %v, wantErr %v", tt.checker.Name(), err, tt.wantErr)
+			}
+			if err != nil && FailureKindOf(err) != tt.wantKind {
+				t.Errorf("%s.Check() kind = %v, want %v", tt.checker.Name(), FailureKindOf(err), tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestManager_WithCheckers(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	errRejected := errors.New("rejected")
+	manager := NewManager(1, 5*time.Second, "", WithSelfHostedJudge(judge.URL), WithCheckers(
+		CheckerFunc("pass", func(ctx context.Context, proxy *Proxy) error { return nil }),
+		CheckerFunc("fail", func(ctx context.Context, proxy *Proxy) error { return errRejected }),
+	))
+	manager.Add(New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+	for result := range manager.Run(context.Background()) {
+		if !errors.Is(result.Err, errRejected) || result.Proxy.Alive() {
+			t.Errorf("Run() result = %v alive %v, want rejected", result.Err, result.Proxy.Alive())
+		}
+		var checkErr *CheckError
+		if !errors.As(result.Err, &checkErr) || checkErr.Check != "fail" {
+			t.Errorf("Run() error = %#v, want a CheckError of check fail", result.Err)
+		}
+		if len(result.Checks) != 2 || !result.Checks[0].Passed || result.Checks[1].Passed {
+			t.Errorf("Run() checks = %+v, want pass then fail", result.Checks)
+		}
+	}
+}
+
+func TestManager_liveness(t *testing.T) {
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer site.Close()
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+
+	tests := []struct {
+		name      string
+		options   []ManagerOption
+		wantAlive bool
+	}{
+		{"default liveness", nil, false},
+		{"replaced liveness", []ManagerOption{WithCheckers(StatusCheck(site.URL, http.StatusNoContent))}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]ManagerOption{WithJudge(judge.URL)}, tt.options...)
+			manager := NewManager(1, 5*time.Second, site.URL, options...)
+			manager.Add(New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+			for result := range manager.Run(context.Background()) {
+				if result.Proxy.Alive() != tt.wantAlive {
+					t.Errorf("Run() alive = %v, want %v, error %v", result.Proxy.Alive(), tt.wantAlive, result.Err)
+				}
+				if len(result.Checks) != 1 || result.Checks[0].Name != "status" || result.Checks[0].Target != site.URL ||
+					result.Checks[0].Passed != tt.wantAlive {
+					t.Errorf("Run() checks = %+v, want a single status check of %s", result.Checks, site.URL)
+				}
+				if result.Timing.Total <= 0 {
+					t.Errorf("Run() timing = %+v, want the timing of the status check", result.Timing)
+				}
+			}
+		})
+	}
+}
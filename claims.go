@@ -0,0 +1,177 @@
+package groxy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attributes sources declare about the proxies they list, Declare normalizes their values so they compare with the
+// values a check verified
+const (
+	// AttrAnonymity is an anonymity level named as by AnonymityLevel.String
+	AttrAnonymity = "anonymity"
+	// AttrCountry is an upper case ISO 3166 country code
+	AttrCountry = "country"
+	// AttrHTTPS is true for proxies tunneling https traffic and false for the others
+	AttrHTTPS = "https"
+	// AttrResponseTime is a duration such as 850ms, plain numbers are read as seconds
+	AttrResponseTime = "response_time"
+)
+
+// Declared returns the attributes the sources of the proxy claim, they are not verified by any check
+func (h *Proxy) Declared() map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	declared := make(map[string]string, len(h.declared))
+	for name, value := range h.declared {
+		declared[name] = value
+	}
+	return declared
+}
+
+// Declare records that a source claims the proxy has an attribute, values of the known attributes are normalized and
+// the ones which cannot be read are ignored. Providers call it for the metadata their lists publish
+func (h *Proxy) Declare(name, value string) {
+	value = normalizeAttribute(name, value)
+	if value == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.declared == nil {
+		h.declared = make(map[string]string)
+	}
+	h.declared[name] = value
+}
+
+// mergeDeclared records the attributes declared for duplicate which proxy has no claim for yet
+func mergeDeclared(proxy, duplicate *Proxy) {
+	declared := duplicate.Declared()
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	for name, value := range declared {
+		if _, ok := proxy.declared[name]; ok {
+			continue
+		}
+		if proxy.declared == nil {
+			proxy.declared = make(map[string]string)
+		}
+		proxy.declared[name] = value
+	}
+}
+
+// Verified returns the attributes measured by checks in the form of the declared ones. Anonymity, response time and
+// https support are known once the proxy passed a check, https only when the check target used tls, and the country
+// once the proxy was located with a GeoDB
+func (h *Proxy) Verified() map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	verified := make(map[string]string)
+	if h.geo.Country != "" {
+		verified[AttrCountry] = h.geo.Country
+	}
+	if !h.alive {
+		return verified
+	}
+	if h.anonymity != AnonymityUnknown {
+		verified[AttrAnonymity] = h.anonymity.String()
+	}
+	if h.timing.TLS > 0 {
+		verified[AttrHTTPS] = "true"
+	}
+	if h.responseTime > 0 {
+		verified[AttrResponseTime] = h.responseTime.String()
+	}
+	return verified
+}
+
+// Claim is an attribute declared by a source next to the value checks measured
+type Claim struct {
+	Name     string
+	Declared string
+	// Verified is empty until a check measured the attribute
+	Verified string
+}
+
+// Checked returns whether the claim was verified by a check
+func (c Claim) Checked() bool {
+	return c.Verified != ""
+}
+
+// Holds returns whether a check confirmed the claim. A proxy at least as anonymous as declared holds an anonymity
+// claim, and one answering within twice the declared response time holds a response time claim since the source
+// measured it from elsewhere. Other attributes must be equal
+func (c Claim) Holds() bool {
+	if !c.Checked() {
+		return false
+	}
+	switch c.Name {
+	case AttrAnonymity:
+		return ParseAnonymityLevel(c.Verified) >= ParseAnonymityLevel(c.Declared)
+	case AttrResponseTime:
+		declared, err := time.ParseDuration(c.Declared)
+		if err != nil {
+			return false
+		}
+		verified, err := time.ParseDuration(c.Verified)
+		return err == nil && verified <= 2*declared
+	}
+	return strings.EqualFold(c.Declared, c.Verified)
+}
+
+// Claims returns the declared attributes of the proxy with their verified value, sorted by name
+func (h *Proxy) Claims() []Claim {
+	declared := h.Declared()
+	verified := h.Verified()
+	claims := make([]Claim, 0, len(declared))
+	for name, value := range declared {
+		claims = append(claims, Claim{Name: name, Declared: value, Verified: verified[name]})
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Name < claims[j].Name })
+	return claims
+}
+
+// normalizeAttribute returns value in the form of the verified attribute name, or an empty string when it cannot be
+// read. Values of unknown attributes are only trimmed
+func normalizeAttribute(name, value string) string {
+	value = strings.TrimSpace(value)
+	switch name {
+	case AttrAnonymity:
+		if level := ParseAnonymityLevel(value); level != AnonymityUnknown {
+			return level.String()
+		}
+		return ""
+	case AttrCountry:
+		return strings.ToUpper(value)
+	case AttrHTTPS:
+		switch strings.ToLower(value) {
+		case "yes", "true", "+", "https", "s", "1":
+			return "true"
+		case "no", "false", "-", "0":
+			return "false"
+		}
+		return ""
+	case AttrResponseTime:
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second)).String()
+		}
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d.String()
+		}
+		return ""
+	}
+	return value
+}
+
+// declaredRank ranks proxies by what their sources claim, the higher the more worth checking first
+func declaredRank(proxy *Proxy) int {
+	proxy.mu.RLock()
+	defer proxy.mu.RUnlock()
+	rank := int(ParseAnonymityLevel(proxy.declared[AttrAnonymity])) * 2
+	if proxy.declared[AttrHTTPS] == "true" {
+		rank++
+	}
+	return rank
+}
@@ -0,0 +1,88 @@
+package groxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProxy_Declare(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{AttrAnonymity, "high_anonymous", "elite"},
+		{AttrAnonymity, "H", "elite"},
+		{AttrAnonymity, "whatever", ""},
+		{AttrCountry, " us", "US"},
+		{AttrHTTPS, "yes", "true"},
+		{AttrHTTPS, "-", "false"},
+		{AttrResponseTime, "0.75", "750ms"},
+		{AttrResponseTime, "2s", "2s"},
+		{AttrResponseTime, "slow", ""},
+		{"uptime", " 99% ", "99%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.value, func(t *testing.T) {
+			proxy := New("1.2.3.4:80", "", "")
+			proxy.Declare(tt.name, tt.value)
+			if got := proxy.Declared()[tt.name]; got != tt.want {
+				t.Errorf("Declared()[%s] = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxy_Claims(t *testing.T) {
+	proxies, _ := parseSpysList("Proxy list\nHttp proxy\nformat\n\n5.6.7.8:3128 DE-H-S! -\n\nFree proxy list", HTTP)
+	proxy := proxies[0]
+	proxy.Declare(AttrResponseTime, "1")
+	want := []Claim{
+		{Name: AttrAnonymity, Declared: "elite"},
+		{Name: AttrCountry, Declared: "DE"},
+		{Name: AttrHTTPS, Declared: "true"},
+		{Name: AttrResponseTime, Declared: "1s"},
+	}
+	if got := proxy.Claims(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Claims() = %+v, want %+v", got, want)
+	}
+
+	proxy.setGeo(Geo{Country: "FR"})
+	proxy.setAlive(Timing{TLS: 100 * time.Millisecond, Total: 1500 * time.Millisecond}, Anonymous)
+	want = []Claim{
+		{Name: AttrAnonymity, Declared: "elite", Verified: "anonymous"},
+		{Name: AttrCountry, Declared: "DE", Verified: "FR"},
+		{Name: AttrHTTPS, Declared: "true", Verified: "true"},
+		{Name: AttrResponseTime, Declared: "1s", Verified: "1.5s"},
+	}
+	got := proxy.Claims()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Claims() = %+v, want %+v", got, want)
+	}
+	var holds []bool
+	for _, claim := range got {
+		holds = append(holds, claim.Holds())
+	}
+	if want := []bool{false, false, true, true}; !reflect.DeepEqual(holds, want) {
+		t.Errorf("Holds() = %v, want %v", holds, want)
+	}
+}
+
+func TestQuery_SortDeclared(t *testing.T) {
+	declared := func(host string, attributes ...string) *Proxy {
+		proxy := New(host, "", "")
+		for i := 0; i < len(attributes); i += 2 {
+			proxy.Declare(attributes[i], attributes[i+1])
+		}
+		return proxy
+	}
+	none := declared("1.1.1.1:80")
+	transparent := declared("2.2.2.2:80", AttrAnonymity, "transparent", AttrHTTPS, "yes")
+	elite := declared("3.3.3.3:80", AttrAnonymity, "elite")
+	eliteHTTPS := declared("4.4.4.4:80", AttrAnonymity, "elite", AttrHTTPS, "yes")
+	got := NewQuery().SortBy(SortDeclared).Apply([]*Proxy{none, transparent, elite, eliteHTTPS})
+	if want := []*Proxy{eliteHTTPS, elite, transparent, none}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() = %v, want %v", hosts(got), hosts(want))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/G5Becks/groxy"
+)
+
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	in := flags.String("in", "", "csv or json file of proxies, as written by harvest or groxy.SaveToFile")
+	out := flags.String("out", "", "file the checked proxies are written to, stdout when empty")
+	format := flags.String("format", "json",
+		"output format: "+strings.Join(formats, ", ")+", only json keeps the check results")
+	concurrency := flags.Int("concurrency", 50, "number of proxies checked at a time")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout for each check")
+	target := flags.String("target", "", "url proxies are checked against, a random well known site when empty")
+	judge := flags.String("judge", "", "proxy judge used to measure anonymity")
+	selfJudge := flags.String("self-judge", "", "url of a judge served by groxy judge, used for every check")
+	contains := flags.String("contains", "", "text the body of -target must contain through the proxy")
+	tlsAddr := flags.String("tls", "", "host:port a tls handshake is verified with through the proxy")
+	sortBy := flags.String("sort", "", "sort the output by a timing phase: connect, handshake, tls, first-byte or total")
+	all := flags.Bool("all", false, "write every proxy checked, not only the ones alive")
+	quiet := flags.Bool("quiet", false, "do not print the result of each check")
+	flags.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	proxies, err := readProxies(*in)
+	if err != nil {
+		return err
+	}
+	var phase groxy.Phase
+	if *sortBy != "" {
+		if phase, err = groxy.ParsePhase(*sortBy); err != nil {
+			return err
+		}
+	}
+	var options []groxy.ManagerOption
+	if *judge != "" {
+		options = append(options, groxy.WithJudge(*judge))
+	}
+	if *selfJudge != "" {
+		options = append(options, groxy.WithSelfHostedJudge(*selfJudge))
+	}
+	if *contains != "" || *tlsAddr != "" {
+		// checkers replace the liveness check of the manager, it is kept first
+		liveness := *target
+		if *selfJudge != "" {
+			liveness = *selfJudge
+		}
+		options = append(options, groxy.WithCheckers(groxy.LivenessCheck(liveness)))
+	}
+	if *contains != "" {
+		if *target == "" {
+			return errors.New("-contains requires -target")
+		}
+		options = append(options, groxy.WithCheckers(groxy.BodyCheck(*target, *contains)))
+	}
+	if *tlsAddr != "" {
+		options = append(options, groxy.WithCheckers(groxy.TLSCheck(*tlsAddr, nil)))
+	}
+	progress := io.Writer(os.Stderr)
+	if *quiet {
+		progress = io.Discard
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	checked := checkProxies(ctx, proxies, *concurrency, *timeout, *target, options, progress)
+
+	var list []*groxy.Proxy
+	alive := 0
+	for _, proxy := range checked {
+		if proxy.Alive() {
+			alive++
+		}
+		if *all || proxy.Alive() {
+			list = append(list, proxy)
+		}
+	}
+	if *sortBy != "" {
+		groxy.SortByPhase(list, phase)
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d proxies alive\n", alive, len(checked))
+	return writeProxiesTo(*out, *format, list)
+}
+
+// checkProxies checks proxies with a groxy.Manager, printing each result to progress, and returns the proxies checked
+// before ctx was done
+func checkProxies(ctx context.Context, proxies []*groxy.Proxy, concurrency int, timeout time.Duration, target string,
+	options []groxy.ManagerOption, progress io.Writer) []*groxy.Proxy {
+	manager := groxy.NewManager(concurrency, timeout, target, options...)
+	manager.Add(proxies...)
+	total := len(manager.Inputs())
+
+	var checked []*groxy.Proxy
+	count := 0
+	for result := range manager.Run(ctx) {
+		count++
+		checked = append(checked, result.Proxy)
+		if result.Err != nil {
+			fmt.Fprintf(progress, "[%d/%d] %s dead: %v\n", count, total, result.Proxy.Host(), result.Err)
+			continue
+		}
+		timing := result.Timing
+		fmt.Fprintf(progress, "[%d/%d] %s alive %s (connect %s, handshake %s, tls %s, first byte %s) %s\n", count, total,
+			result.Proxy.Host(), timing.Total.Round(time.Millisecond), timing.Connect.Round(time.Millisecond),
+			timing.Handshake.Round(time.Millisecond), timing.TLS.Round(time.Millisecond),
+			timing.FirstByte.Round(time.Millisecond), result.Proxy.Anonymity())
+	}
+	if unchecked := manager.Unchecked(); len(unchecked) > 0 {
+		fmt.Fprintf(progress, "stopped with %d proxies unchecked\n", len(unchecked))
+	}
+	return checked
+}
+
+// aliveProxies checks proxies and returns the ones which are alive
+func aliveProxies(proxies []*groxy.Proxy, concurrency int, timeout time.Duration) []*groxy.Proxy {
+	var alive []*groxy.Proxy
+	for _, proxy := range checkProxies(context.Background(), proxies, concurrency, timeout, "", nil, io.Discard) {
+		if proxy.Alive() {
+			alive = append(alive, proxy)
+		}
+	}
+	return alive
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/G5Becks/groxy"
+)
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	in := flags.String("in", "", "csv or json file of proxies, as written by harvest, check or groxy.SaveToFile")
+	out := flags.String("out", "", "file the proxies are written to, stdout when empty")
+	format := flags.String("format", "txt", "output format: "+strings.Join(formats, ", "))
+	protocol := flags.String("protocol", "", "only export proxies using this protocol")
+	geo := addGeoFlags(flags)
+	flags.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	proxies, err := readProxies(*in)
+	if err != nil {
+		return err
+	}
+	if *protocol != "" {
+		proxies = groxy.Filter{Protocols: []groxy.Protocol{groxy.Protocol(*protocol)}}.Apply(proxies)
+	}
+	if proxies, err = geo.apply(proxies); err != nil {
+		return err
+	}
+	return writeProxiesTo(*out, *format, proxies)
+}
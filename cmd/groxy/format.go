@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/G5Becks/groxy"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// formats are the output formats understood by writeProxies
+var formats = []string{"csv", "json", "txt", "url"}
+
+// exportedProxy is the json representation of a proxy
+type exportedProxy struct {
+	ID           string    `json:"id"`
+	Protocol     string    `json:"protocol"`
+	Host         string    `json:"host"`
+	Username     string    `json:"username,omitempty"`
+	Password     string    `json:"password,omitempty"`
+	Alive        bool      `json:"alive"`
+	Anonymity    string    `json:"anonymity"`
+	ResponseTime float64   `json:"response_time_ms"`
+	Country      string    `json:"country,omitempty"`
+	City         string    `json:"city,omitempty"`
+	ASN          uint      `json:"asn,omitempty"`
+	Org          string    `json:"org,omitempty"`
+	LastChecked  time.Time `json:"last_checked,omitempty"`
+	Reliability  float64   `json:"reliability"`
+	Providers    []string  `json:"providers,omitempty"`
+	// Declared are the attributes claimed by the lists of the proxy, which checks may contradict
+	Declared map[string]string `json:"declared,omitempty"`
+}
+
+// writeProxies writes proxies to w in format, one of formats
+// csv is the format read by groxy.FromFile, txt is one host:port per line and url is one proxy url per line
+func writeProxies(w io.Writer, format string, proxies []*groxy.Proxy) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		for _, proxy := range proxies {
+			if err := writer.Write(proxy.AsCSV()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		list := make([]exportedProxy, 0, len(proxies))
+		for _, proxy := range proxies {
+			geo := proxy.Geo()
+			list = append(list, exportedProxy{
+				ID:           proxy.Id(),
+				Protocol:     string(proxy.Protocol()),
+				Host:         proxy.Host(),
+				Username:     proxy.Username(),
+				Password:     proxy.Password(),
+				Alive:        proxy.Alive(),
+				Anonymity:    proxy.Anonymity().String(),
+				ResponseTime: float64(proxy.ResponseTime()) / float64(time.Millisecond),
+				Country:      geo.Country,
+				City:         geo.City,
+				ASN:          geo.ASN,
+				Org:          geo.Org,
+				LastChecked:  proxy.LastChecked(),
+				Reliability:  proxy.Reliability().Score,
+				Providers:    proxy.Providers(),
+				Declared:     proxy.Declared(),
+			})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	case "txt":
+		for _, proxy := range proxies {
+			if _, err := fmt.Fprintln(w, proxy.Host()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "url":
+		for _, proxy := range proxies {
+			u := url.URL{Scheme: string(proxy.Protocol()), Host: proxy.Host()}
+			if proxy.Username() != "" {
+				u.User = url.UserPassword(proxy.Username(), proxy.Password())
+			}
+			if _, err := fmt.Fprintln(w, u.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(formats, ", "))
+}
+
+// writeProxiesTo writes proxies to the file at path in format, an empty path or - writes to stdout
+func writeProxiesTo(path string, format string, proxies []*groxy.Proxy) error {
+	if path == "" || path == "-" {
+		return writeProxies(os.Stdout, format, proxies)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeProxies(f, format, proxies); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readProxies loads the proxies in the file at path, either a csv file read by groxy.FromFile or a json file written
+// by writeProxies, which keeps the check results, entries which cannot be parsed are reported on stderr and skipped
+func readProxies(path string) ([]*groxy.Proxy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []exportedProxy
+	// a csv row can start with [ too when the host is an ipv6 address, it is not valid json though
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' && json.Unmarshal(trimmed, &list) == nil {
+		proxies, skipped := importProxies(list)
+		for _, rejected := range skipped {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, rejected)
+		}
+		return proxies, nil
+	}
+	proxies, err := groxy.FromFile(path)
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, rejected := range merr.Errors {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, rejected)
+		}
+		return proxies, nil
+	}
+	return proxies, err
+}
+
+// importProxies restores the proxies of the json written by writeProxies, the errors are those of the entries skipped
+func importProxies(list []exportedProxy) ([]*groxy.Proxy, []error) {
+	var proxies []*groxy.Proxy
+	var errs []error
+	for i, entry := range list {
+		if _, err := groxy.ParseWithProtocol(groxy.Protocol(entry.Protocol), entry.Host); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %v", i+1, err))
+			continue
+		}
+		if entry.ID != "" {
+			var id groxy.ID
+			if err := id.UnmarshalText([]byte(entry.ID)); err != nil {
+				errs = append(errs, fmt.Errorf("entry %d: %v", i+1, err))
+				continue
+			}
+		}
+		var sources []groxy.SourceRef
+		for _, provider := range entry.Providers {
+			sources = append(sources, groxy.SourceRef{Provider: provider})
+		}
+		proxies = append(proxies, groxy.FromRecord(groxy.Record{
+			ID:           entry.ID,
+			Protocol:     groxy.Protocol(entry.Protocol),
+			Host:         entry.Host,
+			Username:     entry.Username,
+			Password:     entry.Password,
+			Anonymity:    groxy.ParseAnonymityLevel(entry.Anonymity),
+			ResponseTime: time.Duration(entry.ResponseTime * float64(time.Millisecond)),
+			Alive:        entry.Alive,
+			LastChecked:  entry.LastChecked,
+			Country:      entry.Country,
+			City:         entry.City,
+			ASN:          entry.ASN,
+			Org:          entry.Org,
+			Sources:      sources,
+			Declared:     entry.Declared,
+		}))
+	}
+	return proxies, errs
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/G5Becks/groxy"
+	"github.com/G5Becks/groxy/metrics"
+)
+
+func runGateway(args []string) error {
+	flags := flag.NewFlagSet("gateway", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8080", "address the gateway listens on")
+	in := flags.String("in", "", "csv or json file of proxies, as written by harvest, check or groxy.SaveToFile")
+	strategy := flags.String("strategy", "roundrobin", "proxy selection strategy: roundrobin, random, fastest, reliable or lru")
+	retries := flags.Int("retries", 2, "times a failed request is retried through another proxy")
+	maxFailures := flags.Int("max-failures", 3, "consecutive failures before a proxy is evicted, 0 never evicts")
+	dialTimeout := flags.Duration("dial-timeout", 10*time.Second, "timeout for opening a tunnel through a proxy")
+	check := flags.Bool("check", false, "check the proxies before serving and only use the ones alive")
+	concurrency := flags.Int("concurrency", 50, "number of proxies checked at a time with -check")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout for each check with -check")
+	metricsAddr := flags.String("metrics", "", "address Prometheus metrics are served on at /metrics, disabled when empty")
+	geo := addGeoFlags(flags)
+	flags.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	selector, err := groxy.SelectorByName(*strategy)
+	if err != nil {
+		return err
+	}
+	proxies, err := readProxies(*in)
+	if err != nil {
+		return err
+	}
+	if proxies, err = geo.apply(proxies); err != nil {
+		return err
+	}
+	if *check {
+		proxies = aliveProxies(proxies, *concurrency, *timeout)
+	}
+	if len(proxies) == 0 {
+		return errors.New("no proxies to serve")
+	}
+
+	pool := groxy.NewPool(selector, proxies...)
+	pool.SetMaxFailures(*maxFailures)
+	gateway := groxy.NewGateway(pool)
+	gateway.SetRetries(*retries)
+	gateway.SetDialTimeout(*dialTimeout)
+	if *metricsAddr != "" {
+		m := metrics.New()
+		m.ObservePool("gateway", pool)
+		gateway.SetObserver(m)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				fmt.Fprintf(os.Stderr, "serving metrics: %v\n", err)
+			}
+		}()
+	}
+
+	fmt.Fprintf(os.Stderr, "serving %d proxies on %s\n", len(proxies), *listen)
+	return gateway.ListenAndServe(*listen)
+}
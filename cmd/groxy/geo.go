@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/G5Becks/groxy"
+)
+
+// geoFlags are the flags locating proxies with local geoip databases and selecting them by location
+type geoFlags struct {
+	databases  *string
+	countries  *string
+	excludeASN *string
+}
+
+// addGeoFlags registers the geoip flags on flags
+func addGeoFlags(flags *flag.FlagSet) *geoFlags {
+	return &geoFlags{
+		databases:  flags.String("geoip", "", "comma separated mmdb files proxies are located with, such as GeoLite2 City and ASN"),
+		countries:  flags.String("country", "", "comma separated country codes, only proxies located in them are kept"),
+		excludeASN: flags.String("exclude-asn", "", "comma separated autonomous system numbers whose proxies are dropped"),
+	}
+}
+
+// apply locates proxies and returns the ones matching the location flags
+func (g *geoFlags) apply(proxies []*groxy.Proxy) ([]*groxy.Proxy, error) {
+	filter := groxy.Filter{Countries: splitList(*g.countries)}
+	for _, field := range splitList(*g.excludeASN) {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(field), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asn %q", field)
+		}
+		filter.ExcludeASNs = append(filter.ExcludeASNs, uint(asn))
+	}
+	if *g.databases != "" {
+		db, err := groxy.OpenGeoDB(splitList(*g.databases)...)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		if err := db.Enrich(proxies...); err != nil {
+			return nil, err
+		}
+	} else if len(filter.ExcludeASNs) > 0 {
+		return nil, errors.New("-exclude-asn requires -geoip")
+	}
+	return filter.Apply(proxies), nil
+}
+
+// splitList returns the non empty comma separated fields of s
+func splitList(s string) []string {
+	var list []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			list = append(list, field)
+		}
+	}
+	return list
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/G5Becks/groxy"
+)
+
+func runHarvest(args []string) error {
+	flags := flag.NewFlagSet("harvest", flag.ExitOnError)
+	providers := flags.String("providers", "all", "comma separated providers to harvest from: all, none or "+
+		strings.Join(groxy.ProviderNames(), ", "))
+	sources := flags.String("sources", "", "yaml or json file of extra sources to harvest from, see groxy.SourceConfig")
+	out := flags.String("out", "", "file the proxies are written to, stdout when empty")
+	format := flags.String("format", "csv", "output format: "+strings.Join(formats, ", "))
+	timeout := flags.Duration("timeout", time.Minute, "deadline for each provider")
+	quality := flags.Bool("quality", false, "check the proxies and print how many of each provider's proxies are alive, "+
+		"anonymous and fast")
+	fast := flags.Duration("fast", 2*time.Second, "response time under which a live proxy counts as fast for -quality")
+	flags.Parse(args)
+
+	list, err := selectProviders(*providers)
+	if err != nil {
+		return err
+	}
+	if *sources != "" {
+		configs, err := groxy.LoadSources(*sources)
+		if err != nil {
+			return err
+		}
+		configured, err := groxy.ProvidersFromSources(configs)
+		if err != nil {
+			return err
+		}
+		list = append(list, configured...)
+	}
+	if len(list) == 0 {
+		return errors.New("no providers to harvest from")
+	}
+	for i, provider := range list {
+		list[i] = reportProgress(provider)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	harvester := groxy.NewHarvester(list...)
+	harvester.SetTimeout(*timeout)
+	report := harvester.HarvestContext(ctx)
+
+	// the proxies claimed to be the most anonymous come first so they are checked first
+	proxies := groxy.NewQuery().SortBy(groxy.SortDeclared).Apply(groxy.Distinct(report.Proxies))
+	for _, failed := range report.Failed() {
+		fmt.Fprintf(os.Stderr, "%s failed after %s: %v\n", failed.Source, failed.Duration.Round(time.Millisecond),
+			failed.Err)
+	}
+	fmt.Fprintf(os.Stderr, "harvested %d proxies, %d distinct, in %s\n", len(report.Proxies), len(proxies),
+		report.Duration.Round(time.Millisecond))
+	if *quality {
+		proxies = checkProxies(ctx, proxies, 50, 10*time.Second, "", nil, io.Discard)
+		printQuality(groxy.QualityReport(proxies, *fast))
+	}
+	return writeProxiesTo(*out, *format, proxies)
+}
+
+// selectProviders returns the built-in providers named in a comma separated list, all selects every provider and none
+// selects no provider
+func selectProviders(names string) ([]groxy.Provider, error) {
+	switch names {
+	case "", "all":
+		return groxy.WithAllProviders(), nil
+	case "none":
+		return nil, nil
+	}
+	var list []groxy.Provider
+	for _, name := range strings.Split(names, ",") {
+		provider, err := groxy.ProviderByName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, provider)
+	}
+	return list, nil
+}
+
+// reportProgress wraps provider to print how many proxies it found as soon as it returns
+func reportProgress(provider groxy.Provider) groxy.Provider {
+	return func(ctx context.Context) groxy.ProviderResponse {
+		t0 := time.Now()
+		resp := provider(ctx)
+		status := "ok"
+		if resp.Err != nil {
+			status = "error"
+		}
+		fmt.Fprintf(os.Stderr, "%-14s %5d proxies %4d rejected %8s %s\n", resp.Source, len(resp.Proxies),
+			len(resp.Rejected), time.Since(t0).Round(time.Millisecond), status)
+		return resp
+	}
+}
+
+// printQuality prints how the proxies of each provider fared in their checks, the providers with the most live proxies
+// first
+func printQuality(report []groxy.SourceQuality) {
+	sort.SliceStable(report, func(i, j int) bool { return report[i].AliveRatio() > report[j].AliveRatio() })
+	for _, quality := range report {
+		fmt.Fprintf(os.Stderr, "%-14s %5d proxies %5d alive (%3.0f%%) %5d anonymous %5d fast\n", quality.Provider,
+			quality.Proxies, quality.Alive, quality.AliveRatio()*100, quality.Anonymous, quality.Fast)
+	}
+}
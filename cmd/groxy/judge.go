@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/G5Becks/groxy"
+)
+
+func runJudge(args []string) error {
+	flags := flag.NewFlagSet("judge", flag.ExitOnError)
+	listen := flags.String("listen", ":8081", "address the judge listens on")
+	certFile := flags.String("cert", "", "tls certificate file, serves https when set together with -key")
+	keyFile := flags.String("key", "", "tls key file")
+	flags.Parse(args)
+
+	fmt.Fprintf(os.Stderr, "judge listening on %s\n", *listen)
+	if *certFile != "" && *keyFile != "" {
+		return http.ListenAndServeTLS(*listen, *certFile, *keyFile, groxy.NewJudge())
+	}
+	return http.ListenAndServe(*listen, groxy.NewJudge())
+}
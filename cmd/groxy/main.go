@@ -0,0 +1,51 @@
+// Command groxy harvests, checks and serves proxies using the groxy package
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a groxy subcommand, args are the command line arguments following the subcommand name
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"harvest", "fetch proxies from the built-in providers", runHarvest},
+	{"check", "check which proxies of a list are alive and how anonymous they are", runCheck},
+	{"export", "convert a proxy list to another format", runExport},
+	{"gateway", "serve a local forward proxy which rotates through a proxy list", runGateway},
+	{"judge", "serve a proxy judge for self-hosted checks", runJudge},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: groxy <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "run groxy <command> -h for the flags of a command")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "groxy %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
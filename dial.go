@@ -0,0 +1,98 @@
+package groxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DialContext opens a tcp connection to addr tunneled through the proxy
+// SOCKS proxies are dialled with their socks version, HTTP proxies are asked to open a tunnel with the CONNECT method
+func (h *Proxy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if h.Protocol().IsSOCKS() {
+		return h.socksDialer(&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	return h.dialConnect(ctx, addr)
+}
+
+// dialConnect asks an http proxy to open a tunnel to addr
+func (h *Proxy) dialConnect(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", h.Host())
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if h.Username() != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(h.Username() + ":" + h.Password()))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, handshakeErr(ctx, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, handshakeErr(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		failure := statusFailure(addr, resp.StatusCode)
+		failure.Err = fmt.Errorf("proxy %s refused CONNECT", h.Host())
+		return nil, failure
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// closeOnDone closes conn once ctx is done, so a handshake blocked on conn is aborted like net.Dialer aborts a dial
+// The returned stop reports false when conn was closed already
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+}
+
+// handshakeErr returns the error of ctx for a handshake aborted by closeOnDone, err otherwise
+func handshakeErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// bufferedConn is a net.Conn whose first bytes were already read into r
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
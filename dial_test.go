@@ -0,0 +1,52 @@
+package groxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProxy_DialContext_canceled(t *testing.T) {
+	// the proxy accepts connections and never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// held open and silent until the test ends
+			defer conn.Close()
+		}
+	}()
+
+	for _, protocol := range []Protocol{HTTP, SOCKS4} {
+		t.Run(string(protocol), func(t *testing.T) {
+			proxy := NewWithProtocol(protocol, l.Addr().String(), "", "")
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			done := make(chan error, 1)
+			go func() {
+				conn, err := proxy.DialContext(ctx, "tcp", "1.2.3.4:80")
+				if conn != nil {
+					conn.Close()
+				}
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("DialContext() error = %v, want %v", err, context.Canceled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("DialContext() did not return once its context was cancelled")
+			}
+		})
+	}
+}
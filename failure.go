@@ -0,0 +1,170 @@
+package groxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// FailureKind categorises why a proxy failed a check
+type FailureKind int
+
+const (
+	// FailureUnknown is a failure which fits no other kind
+	FailureUnknown FailureKind = iota
+	// FailureRefused means the proxy, or the target through it, refused the connection
+	FailureRefused
+	// FailureTimeout means the proxy did not answer in time
+	FailureTimeout
+	// FailureReset means the connection was closed or reset before a response was read
+	FailureReset
+	// FailureTLS means the tls handshake with the target failed, usually because the proxy intercepts tls
+	FailureTLS
+	// FailureProxyAuth means the proxy requires credentials, or rejected the ones given, it is likely a paid proxy
+	FailureProxyAuth
+	// FailureBadStatus means the response status was not the one expected
+	FailureBadStatus
+	// FailureContentMismatch means the response body was not the one expected
+	FailureContentMismatch
+	// FailureAnonymityLeak means the proxy revealed the client ip address or its own use
+	FailureAnonymityLeak
+	// FailureBanned means the target refuses to serve the proxy, see TargetProfile
+	FailureBanned
+)
+
+var failureNames = []string{"unknown", "refused", "timeout", "reset", "tls", "proxy-auth", "bad-status",
+	"content-mismatch", "anonymity-leak", "banned"}
+
+// String returns the name of the failure kind
+func (k FailureKind) String() string {
+	if k < 0 || int(k) >= len(failureNames) {
+		return failureNames[FailureUnknown]
+	}
+	return failureNames[k]
+}
+
+// Retryable returns whether a proxy failing this way may pass when checked again soon
+func (k FailureKind) Retryable() bool {
+	return k == FailureTimeout || k == FailureReset || k == FailureUnknown
+}
+
+// CheckError is the error of a TestResult whose proxy failed a check
+type CheckError struct {
+	Kind FailureKind
+	// Check is the name of the Checker or target profile which failed, it is empty for the liveness request
+	Check string
+	// Target is the url or address the proxy was checked against
+	Target string
+	// StatusCode is the status of the response, zero when no response was received
+	StatusCode int
+	// Err is the underlying error, it may be nil for bad statuses
+	Err error
+}
+
+// Error describes the failure
+func (e *CheckError) Error() string {
+	var b strings.Builder
+	b.WriteString("groxy: ")
+	if e.Check != "" {
+		b.WriteString(e.Check + " check: ")
+	}
+	b.WriteString(e.Kind.String())
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Target != "" {
+		b.WriteString(" from " + e.Target)
+	}
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// FailureKindOf returns the kind of a failed check, the Kind of a *CheckError in err's chain or the kind guessed from
+// the error otherwise
+func FailureKindOf(err error) FailureKind {
+	var checkErr *CheckError
+	if errors.As(err, &checkErr) {
+		return checkErr.Kind
+	}
+	return classifyError(err)
+}
+
+// statusFailure returns the error of a check which received an unexpected response status
+func statusFailure(target string, statusCode int) *CheckError {
+	kind := FailureBadStatus
+	if statusCode == http.StatusProxyAuthRequired {
+		kind = FailureProxyAuth
+	}
+	return &CheckError{Kind: kind, Target: target, StatusCode: statusCode}
+}
+
+// requestFailure returns the error of a check whose request to target failed with err
+func requestFailure(target string, err error) *CheckError {
+	var checkErr *CheckError
+	if errors.As(err, &checkErr) {
+		return checkErr
+	}
+	return &CheckError{Kind: classifyError(err), Target: target, Err: err}
+}
+
+// checkFailure returns the error of a proxy which failed the check named name with err
+func checkFailure(name string, err error) *CheckError {
+	failure := &CheckError{Kind: classifyError(err), Err: err}
+	var checkErr *CheckError
+	if errors.As(err, &checkErr) {
+		copied := *checkErr
+		failure = &copied
+	}
+	failure.Check = name
+	return failure
+}
+
+// classifyError guesses the kind of failure from an error returned by a request through a proxy
+func classifyError(err error) FailureKind {
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certErr x509.CertificateInvalidError
+	var alertErr tls.AlertError
+	switch {
+	case err == nil:
+		return FailureUnknown
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return FailureReset
+	case errors.As(err, &recordErr), errors.As(err, &authorityErr), errors.As(err, &hostnameErr),
+		errors.As(err, &certErr), errors.As(err, &alertErr):
+		return FailureTLS
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "proxy authentication required"), strings.Contains(msg, "authentication failed"),
+		strings.Contains(msg, "no acceptable authentication methods"):
+		return FailureProxyAuth
+	case strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+		return FailureTLS
+	case strings.Contains(msg, "connection refused"):
+		return FailureRefused
+	case strings.Contains(msg, "connection reset"):
+		return FailureReset
+	}
+	return FailureUnknown
+}
@@ -0,0 +1,88 @@
+package groxy
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FailureKind
+	}{
+		{"nil", nil, FailureUnknown},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, FailureRefused},
+		{"reset", &url.Error{Op: "Get", Err: syscall.ECONNRESET}, FailureReset},
+		{"eof", &url.Error{Op: "Get", Err: io.EOF}, FailureReset},
+		{"deadline", fmt.Errorf("dial: %w", context.DeadlineExceeded), FailureTimeout},
+		{"untrusted certificate", &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, FailureTLS},
+		{"socks auth", errors.New("socks connect tcp 1.2.3.4:1080: username/password authentication failed"),
+			FailureProxyAuth},
+		{"other", errors.New("boom"), FailureUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_failures(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	answering := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+	}
+	paid := answering(http.StatusProxyAuthRequired)
+	defer paid.Close()
+	broken := answering(http.StatusServiceUnavailable)
+	defer broken.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		proxy      string
+		wantKind   FailureKind
+		wantStatus int
+	}{
+		{"proxy auth required", paid.URL, FailureProxyAuth, http.StatusProxyAuthRequired},
+		{"bad status", broken.URL, FailureBadStatus, http.StatusServiceUnavailable},
+		{"refused", closed.URL, FailureRefused, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(1, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+			manager.Add(New(strings.TrimPrefix(tt.proxy, "http://"), "", ""))
+			for result := range manager.Run(context.Background()) {
+				var checkErr *CheckError
+				if result.Proxy == nil || !errors.As(result.Err, &checkErr) {
+					t.Fatalf("Run() result = %+v, want a CheckError", result)
+				}
+				if checkErr.Kind != tt.wantKind || checkErr.StatusCode != tt.wantStatus || checkErr.Target != judge.URL {
+					t.Errorf("Run() error = %+v, want kind %v status %v target %v", checkErr, tt.wantKind,
+						tt.wantStatus, judge.URL)
+				}
+				if got := result.Proxy.History().Records()[0].ErrClass; got != tt.wantKind.String() {
+					t.Errorf("History() error class = %v, want %v", got, tt.wantKind)
+				}
+			}
+		})
+	}
+}
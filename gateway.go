@@ -0,0 +1,199 @@
+package groxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hopHeaders are the hop-by-hop headers which are not forwarded by the gateway, see RFC 7230 section 6.1
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Gateway is a local forward proxy server, every request it receives is sent through a proxy chosen from a Pool
+// Plain http requests are forwarded with a RoundTripper, https requests sent with the CONNECT method are tunneled through
+// the chosen proxy. The pool's Selector decides the rotation policy
+type Gateway struct {
+	pool        *Pool
+	transport   *RoundTripper
+	retries     int
+	dialTimeout time.Duration
+	observer    Observer
+}
+
+// NewGateway constructs a gateway which forwards requests through the proxies in pool
+// Failed connections are retried through a different proxy twice and tunnels wait 10 seconds for the upstream proxy
+func NewGateway(pool *Pool) *Gateway {
+	return &Gateway{pool: pool, transport: NewRoundTripper(pool), retries: 2, dialTimeout: 10 * time.Second}
+}
+
+// SetRetries sets how many times a failed request or tunnel is retried through a different proxy
+func (g *Gateway) SetRetries(retries int) {
+	g.retries = retries
+	g.transport.SetRetries(retries)
+}
+
+// SetDialTimeout sets how long opening a tunnel through an upstream proxy may take
+func (g *Gateway) SetDialTimeout(timeout time.Duration) {
+	g.dialTimeout = timeout
+}
+
+// ListenAndServe listens on the tcp address addr and serves proxy requests until it fails
+func (g *Gateway) ListenAndServe(addr string) error {
+	server := &http.Server{Addr: addr, Handler: g}
+	return server.ListenAndServe()
+}
+
+// ServeHTTP implements http.Handler
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		g.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "groxy gateway only accepts proxy requests", http.StatusBadRequest)
+		return
+	}
+	g.forward(w, r)
+}
+
+// forward sends a plain http request through the pool and copies the response back to the client
+func (g *Gateway) forward(w http.ResponseWriter, r *http.Request) {
+	outReq := r.Clone(r.Context())
+	outReq.RequestURI = ""
+	if r.ContentLength == 0 {
+		// server requests always have a body, an empty one must not stop the request being retried
+		outReq.Body = nil
+	}
+	removeHopHeaders(outReq.Header)
+
+	resp, err := g.transport.RoundTrip(outReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel opens a connection to the CONNECT target through the pool and copies bytes in both directions until either side
+// closes
+func (g *Gateway) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	upstream, err := g.dial(r.Context(), r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	client, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	if _, err := rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(upstream, rw)
+		closeWrite(upstream)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(client, upstream)
+		closeWrite(client)
+	}()
+	wg.Wait()
+}
+
+// dial connects to addr through a proxy from the pool, retrying through different proxies on failure
+func (g *Gateway) dial(ctx context.Context, addr string) (net.Conn, error) {
+	tried := make(map[*Proxy]bool)
+	var lastErr error
+	for attempt := 0; attempt <= g.retries; attempt++ {
+		proxy, err := g.pool.NextExcluding(tried)
+		if err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		tried[proxy] = true
+
+		start := time.Now()
+		dialCtx, cancel := context.WithTimeout(ctx, g.dialTimeout)
+		conn, err := proxy.DialContext(dialCtx, "tcp", addr)
+		cancel()
+		if g.observer != nil {
+			g.observer.ProxyUsed(proxy, time.Since(start), err)
+		}
+		if err == nil {
+			g.pool.Success(proxy)
+			return conn, nil
+		}
+		lastErr = err
+		if g.pool.Failure(proxy) {
+			g.transport.evict(proxy)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// closeWrite half closes conn when possible so the other side sees EOF
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+// removeHopHeaders deletes the hop-by-hop headers, including the ones named by the Connection header
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
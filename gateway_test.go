@@ -0,0 +1,141 @@
+package groxy
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upstreamProxy is a minimal http proxy which forwards plain requests and tunnels CONNECT requests
+func upstreamProxy(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			outReq := r.Clone(r.Context())
+			outReq.RequestURI = ""
+			resp, err := http.DefaultTransport.RoundTrip(outReq)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		client, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer client.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\n\r\n")
+		rw.Flush()
+		go io.Copy(target, rw)
+		io.Copy(client, target)
+	}))
+}
+
+func TestGateway_ServeHTTP(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer secure.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	pool := NewPool(nil, New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+	gateway := httptest.NewServer(NewGateway(pool))
+	defer gateway.Close()
+	gatewayURL, _ := url.Parse(gateway.URL)
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(gatewayURL),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"forwards plain http", plain.URL, "plain"},
+		{"tunnels https with CONNECT", secure.URL, "secure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("Get() body = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestGateway_ServeHTTP_retry(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// round robin tries the dead proxy first
+	pool := NewPool(nil, New(strings.TrimPrefix(dead.URL, "http://"), "", ""),
+		New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+	gateway := httptest.NewServer(NewGateway(pool))
+	defer gateway.Close()
+	gatewayURL, _ := url.Parse(gateway.URL)
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{Proxy: http.ProxyURL(gatewayURL)}}
+
+	resp, err := client.Get(plain.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "plain" {
+		t.Errorf("Get() = %v %q, want 200 %q", resp.StatusCode, body, "plain")
+	}
+}
+
+func TestGateway_ServeHTTP_emptyPool(t *testing.T) {
+	gateway := httptest.NewServer(NewGateway(NewPool(nil)))
+	defer gateway.Close()
+	gatewayURL, _ := url.Parse(gateway.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(gatewayURL)}}
+
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Get() status = %v, want %v", resp.StatusCode, http.StatusBadGateway)
+	}
+}
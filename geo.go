@@ -0,0 +1,146 @@
+package groxy
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Geo is the location and network of a proxy's ip address
+type Geo struct {
+	// Country is the ISO 3166 country code
+	Country string
+	// City is the english name of the city
+	City string
+	// ASN is the number of the autonomous system announcing the address, datacenters and hosting providers have their own
+	ASN uint
+	// Org is the organisation owning the autonomous system
+	Org string
+}
+
+// IsZero returns whether nothing is known about the location
+func (g Geo) IsZero() bool {
+	return g == Geo{}
+}
+
+// merge fills the unknown fields of g with the fields of other
+func (g Geo) merge(other Geo) Geo {
+	if g.Country == "" {
+		g.Country = other.Country
+	}
+	if g.City == "" {
+		g.City = other.City
+	}
+	if g.ASN == 0 {
+		g.ASN = other.ASN
+		g.Org = other.Org
+	}
+	return g
+}
+
+// mmdbRecord holds the fields read from MaxMind databases, it decodes Country, City and ASN databases alike
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN uint   `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// GeoDB looks up the location and network of ip addresses in local MaxMind format (mmdb) databases, such as GeoLite2
+// City and GeoLite2 ASN, no network calls are made
+type GeoDB struct {
+	readers []*maxminddb.Reader
+}
+
+// OpenGeoDB opens the mmdb files at paths, lookups merge what each database knows with the first ones taking precedence
+func OpenGeoDB(paths ...string) (*GeoDB, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("groxy: no geoip database given")
+	}
+	db := &GeoDB{}
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("groxy: open geoip database %s: %w", path, err)
+		}
+		db.readers = append(db.readers, reader)
+	}
+	return db, nil
+}
+
+// Lookup returns what the databases know about ip, the zero Geo when it is in none of them
+func (db *GeoDB) Lookup(ip net.IP) (Geo, error) {
+	var geo Geo
+	for _, reader := range db.readers {
+		var record mmdbRecord
+		if err := reader.Lookup(ip, &record); err != nil {
+			return geo, fmt.Errorf("groxy: geoip lookup of %s: %w", ip, err)
+		}
+		geo = geo.merge(Geo{
+			Country: record.Country.ISOCode,
+			City:    record.City.Names["en"],
+			ASN:     record.ASN,
+			Org:     record.Org,
+		})
+	}
+	return geo, nil
+}
+
+// Enrich looks up the address of each proxy and records its location on the proxy, see Proxy.Geo. Proxies given by
+// hostname are skipped since resolving them would need the network
+func (db *GeoDB) Enrich(proxies ...*Proxy) error {
+	for _, proxy := range proxies {
+		host, _, err := net.SplitHostPort(proxy.Host())
+		if err != nil {
+			host = proxy.Host()
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		geo, err := db.Lookup(ip)
+		if err != nil {
+			return err
+		}
+		if !geo.IsZero() {
+			proxy.setGeo(geo)
+		}
+	}
+	return nil
+}
+
+// Close releases the databases
+func (db *GeoDB) Close() error {
+	var result error
+	for _, reader := range db.readers {
+		if err := reader.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// Geo returns the location of the proxy, its Country is the one reported by Country
+func (h *Proxy) Geo() Geo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	geo := h.geo
+	geo.Country = h.country
+	return geo
+}
+
+// setGeo records the location of the proxy looked up in a GeoDB, it replaces the country the proxy was listed with
+func (h *Proxy) setGeo(geo Geo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.geo = geo
+	if geo.Country != "" {
+		h.country = geo.Country
+	}
+}
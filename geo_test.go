@@ -0,0 +1,183 @@
+package groxy
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeMMDB writes an ipv4 MaxMind database mapping single addresses to records, records are built from strings,
+// uint32s, maps and slices
+func writeMMDB(t *testing.T, records map[string]map[string]interface{}) string {
+	const empty = -1
+	nodes := [][2]int{{empty, empty}}
+	// records pointing to data hold -2 - offset until the node count is known
+	var data []byte
+	for ip, record := range records {
+		addr := net.ParseIP(ip).To4()
+		offset := len(data)
+		data = append(data, mmdbEncode(record)...)
+		node := 0
+		for bit := 0; bit < 32; bit++ {
+			side := int(addr[bit/8]>>(7-uint(bit%8))) & 1
+			if bit == 31 {
+				nodes[node][side] = -2 - offset
+				break
+			}
+			if nodes[node][side] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][side] = len(nodes) - 1
+			}
+			node = nodes[node][side]
+		}
+	}
+	count := len(nodes)
+	var db []byte
+	for _, node := range nodes {
+		for _, value := range node {
+			switch {
+			case value == empty:
+				value = count
+			case value < empty:
+				value = count + 16 + (-2 - value)
+			}
+			db = append(db, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, data...)
+	db = append(db, "\xAB\xCD\xEFMaxMind.com"...)
+	db = append(db, mmdbEncode(map[string]interface{}{
+		"binary_format_major_version": uint32(2),
+		"binary_format_minor_version": uint32(0),
+		"build_epoch":                 uint32(1),
+		"database_type":               "groxy-test",
+		"description":                 map[string]interface{}{"en": "groxy test database"},
+		"ip_version":                  uint32(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint32(24),
+	})...)
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := ioutil.WriteFile(path, db, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// mmdbEncode encodes v in the MaxMind DB data section format
+func mmdbEncode(v interface{}) []byte {
+	control := func(kind int, size int) []byte {
+		var b []byte
+		switch {
+		case size < 29:
+			b = []byte{byte(size)}
+		default:
+			b = []byte{29, byte(size - 29)}
+		}
+		if kind > 7 {
+			return append([]byte{b[0]}, append([]byte{byte(kind - 7)}, b[1:]...)...)
+		}
+		b[0] |= byte(kind << 5)
+		return b
+	}
+	switch x := v.(type) {
+	case string:
+		return append(control(2, len(x)), x...)
+	case uint32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, x)
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		return append(control(6, len(b)), b...)
+	case map[string]interface{}:
+		var keys []string
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := control(7, len(x))
+		for _, key := range keys {
+			out = append(out, mmdbEncode(key)...)
+			out = append(out, mmdbEncode(x[key])...)
+		}
+		return out
+	case []interface{}:
+		out := control(11, len(x))
+		for _, item := range x {
+			out = append(out, mmdbEncode(item)...)
+		}
+		return out
+	}
+	panic("unsupported type")
+}
+
+func TestGeoDB(t *testing.T) {
+	city := writeMMDB(t, map[string]map[string]interface{}{
+		"8.8.8.8": {
+			"country": map[string]interface{}{"iso_code": "US"},
+			"city":    map[string]interface{}{"names": map[string]interface{}{"en": "Mountain View"}},
+		},
+		"1.2.3.4": {"country": map[string]interface{}{"iso_code": "AU"}},
+	})
+	asn := writeMMDB(t, map[string]map[string]interface{}{
+		"8.8.8.8": {"autonomous_system_number": uint32(15169), "autonomous_system_organization": "GOOGLE"},
+	})
+	db, err := OpenGeoDB(city, asn)
+	if err != nil {
+		t.Fatalf("OpenGeoDB() error = %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name  string
+		proxy *Proxy
+		want  Geo
+	}{
+		{"both databases", New("8.8.8.8:3128", "", ""), Geo{Country: "US", City: "Mountain View", ASN: 15169,
+			Org: "GOOGLE"}},
+		{"country only", New("1.2.3.4:8080", "", ""), Geo{Country: "AU"}},
+		{"unknown address", New("9.9.9.9:8080", "", ""), Geo{}},
+		{"hostname", New("proxy.example.com:8080", "", ""), Geo{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.Enrich(tt.proxy); err != nil {
+				t.Fatalf("Enrich() error = %v", err)
+			}
+			if got := tt.proxy.Geo(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Geo() = %+v, want %+v", got, tt.want)
+			}
+			if got := FromRecord(tt.proxy.Record()).Geo(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromRecord().Geo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_ASNs(t *testing.T) {
+	datacenter := FromRecord(Record{Host: "1.1.1.1:80", ASN: 16509, Org: "AMAZON"})
+	residential := FromRecord(Record{Host: "2.2.2.2:80", ASN: 7922, Org: "COMCAST"})
+	unknown := FromRecord(Record{Host: "3.3.3.3:80"})
+	proxies := []*Proxy{datacenter, residential, unknown}
+	tests := []struct {
+		name  string
+		query Query
+		want  []*Proxy
+	}{
+		{"asns", NewQuery().ASNs(7922), []*Proxy{residential}},
+		{"exclude asns", NewQuery().ExcludeASNs(16509, 14061), []*Proxy{residential, unknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Apply(proxies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", hosts(got), hosts(tt.want))
+			}
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package groxy
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	multierror "github.com/hashicorp/go-multierror"
 )
@@ -15,17 +18,63 @@ import (
 // ProviderResponse is a struct returned from a proxy provider function, it contains a list of proxies and a possible error
 // which in some cases may be a github.com/hashicorp/go-multierror
 type ProviderResponse struct {
+	Source  string
 	Proxies []*Proxy
 	Err     error
+	// Rejected are the entries of the source which could not be parsed as proxies
+	Rejected []*ParseError
 }
 
 // Provider is any function that can fetch proxies from a remote location, the function returns a provider response
-type Provider func() ProviderResponse
+// Providers should stop fetching and return the context error once ctx is done
+type Provider func(ctx context.Context) ProviderResponse
+
+// ProviderFunc adapts a provider written before providers took a context, fn runs to completion and is given up on
+// once the harvest deadline passes. The response is named after fn when it names no source
+func ProviderFunc(fn func() ProviderResponse) Provider {
+	return func(ctx context.Context) ProviderResponse {
+		resp := fn()
+		if resp.Source == "" {
+			resp.Source = providerName(fn)
+		}
+		return resp
+	}
+}
+
+// ProviderReport describes the outcome of running a single provider during a harvest
+type ProviderReport struct {
+	Source   string
+	Proxies  int
+	Duration time.Duration
+	Err      error
+	Rejected []*ParseError
+}
+
+// HarvestReport is returned by HarvestContext, it contains every proxy found and a report for each provider
+type HarvestReport struct {
+	Proxies   []*Proxy
+	Providers []ProviderReport
+	Duration  time.Duration
+}
+
+// Failed returns the reports of the providers which returned an error
+func (r *HarvestReport) Failed() []ProviderReport {
+	var failed []ProviderReport
+	for _, report := range r.Providers {
+		if report.Err != nil {
+			failed = append(failed, report)
+		}
+	}
+	return failed
+}
 
 // Harvester is a struct that uses a list of provider functions to harvest proxies and stores the harvested proxies in a slice
 type Harvester struct {
+	mu        sync.Mutex
 	providers []Provider
 	proxies   []*Proxy
+	timeout   time.Duration
+	observer  Observer
 }
 
 // NewHarvester constructs a new harvester struct using the list of provider functions passed in as arguments to harvest proxies
@@ -34,211 +83,306 @@ func NewHarvester(providers ...Provider) *Harvester {
 	return &Harvester{providers: providers}
 }
 
+// SetTimeout sets the deadline given to each provider during a harvest, a zero duration means no deadline
+func (h *Harvester) SetTimeout(timeout time.Duration) {
+	h.timeout = timeout
+}
+
 // Harvest fetches proxies using the list of providers contained in Harvester's internal providers list
 // The results are stored in the proxies list and can be obtained using the Proxies() method
 func (h *Harvester) Harvest() {
-	for _, provider := range h.providers {
-		resp := provider()
-		if resp.Err == nil {
-			h.proxies = append(h.proxies, resp.Proxies...)
-		}
-	}
+	h.HarvestContext(context.Background())
 }
 
-// Proxies returns the list of proxies contained in the Harvester struct
-func (h *Harvester) Proxies() []*Proxy {
-	return h.proxies
-}
-
-func getBody(site string) (string, error) {
-	var bodyString string
-	client := http.Client{}
-	resp, err := client.Get(site)
-	if err != nil {
-		return "", err
+// HarvestContext runs every provider concurrently and waits for all of them to finish, fail, or run out of time
+// Providers which do not return before ctx is done, or before their own deadline set with SetTimeout, are reported with the
+// context error. The proxies found are also stored in the proxies list and can be obtained using the Proxies() method
+func (h *Harvester) HarvestContext(ctx context.Context) *HarvestReport {
+	t0 := time.Now()
+	responses := make([]ProviderResponse, len(h.providers))
+	durations := make([]time.Duration, len(h.providers))
 
+	wg := sync.WaitGroup{}
+	wg.Add(len(h.providers))
+	for i, provider := range h.providers {
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			start := time.Now()
+			responses[i] = h.runProvider(ctx, provider)
+			durations[i] = time.Since(start)
+		}(i, provider)
 	}
-	if resp.StatusCode == http.StatusOK {
-		bodyBytes, err2 := ioutil.ReadAll(resp.Body)
-		if err2 != nil {
-			return "", err
-		}
-		bodyString = string(bodyBytes)
+	wg.Wait()
 
+	report := &HarvestReport{}
+	for i, resp := range responses {
+		report.Proxies = append(report.Proxies, resp.Proxies...)
+		report.Providers = append(report.Providers, ProviderReport{
+			Source:   resp.Source,
+			Proxies:  len(resp.Proxies),
+			Duration: durations[i],
+			Err:      resp.Err,
+			Rejected: resp.Rejected,
+		})
 	}
-	defer resp.Body.Close()
-	return bodyString, nil
+	report.Duration = time.Since(t0)
+
+	h.mu.Lock()
+	h.proxies = append(h.proxies, report.Proxies...)
+	h.mu.Unlock()
+	return report
 }
 
-// ProxyListDL is a provider which fetches proxies from https://www.proxy-list.download
-func ProxyListDL() ProviderResponse {
-	var resultErr error
-	var proxies []*Proxy
-	kinds := []string{"http", "https"}
-	respStream := make(chan ProviderResponse, len(kinds))
-	get := func(kind string) {
-		var list []*Proxy
-		site := fmt.Sprintf("https://www.proxy-list.download/api/v1/get?type=%s", kind)
+// emitterKey is the context key of the function Emit hands proxies to
+type emitterKey struct{}
 
-		bodyString, err := getBody(site)
-		if err != nil {
-			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
+// Emit hands proxies to the harvest running the provider as soon as they are found, it lets HarvestStream send them
+// before the provider returns. Providers should still return every proxy in their response, Emit does nothing outside
+// HarvestStream. It blocks until the proxies are taken or ctx is done
+func Emit(ctx context.Context, proxies ...*Proxy) {
+	emit, ok := ctx.Value(emitterKey{}).(func(context.Context, *Proxy))
+	if !ok {
+		return
+	}
+	for _, proxy := range proxies {
+		emit(ctx, proxy)
+	}
+}
+
+// HarvestStream runs every provider concurrently and sends proxies on the returned channel as soon as their provider
+// emits them with Emit, or returns them. Proxies with the same host as a proxy already sent are dropped once their
+// sources are merged into it. The channel is closed once every provider returned or gave up, the caller must drain it
+// or cancel ctx
+// The proxies sent are also stored in the proxies list and can be obtained using the Proxies() method
+func (h *Harvester) HarvestStream(ctx context.Context) <-chan *Proxy {
+	out := make(chan *Proxy)
+	var mu sync.Mutex
+	seen := make(map[string]*Proxy)
+	var sent []*Proxy
+	// closing is held for writing while out is closed so no send can be in flight
+	var closing sync.RWMutex
+	closed := false
+	send := func(ctx context.Context, proxy *Proxy) {
+		closing.RLock()
+		defer closing.RUnlock()
+		mu.Lock()
+		if kept, ok := seen[proxy.Host()]; ok || closed {
+			mu.Unlock()
+			if ok {
+				mergeDuplicate(kept, proxy)
+			}
 			return
 		}
+		seen[proxy.Host()] = proxy
+		mu.Unlock()
+		select {
+		case out <- proxy:
+			mu.Lock()
+			sent = append(sent, proxy)
+			mu.Unlock()
+		case <-ctx.Done():
+		}
+	}
+	emitCtx := context.WithValue(ctx, emitterKey{}, send)
 
-		for _, ip := range strings.Fields(bodyString) {
-			proxy := New(ip, "", "")
-
-			list = append(list, proxy)
+	go func() {
+		wg := sync.WaitGroup{}
+		wg.Add(len(h.providers))
+		for _, provider := range h.providers {
+			go func(provider Provider) {
+				defer wg.Done()
+				resp := h.runProvider(emitCtx, provider)
+				for _, proxy := range resp.Proxies {
+					send(ctx, proxy)
+				}
+			}(provider)
 		}
-		respStream <- ProviderResponse{Proxies: list, Err: nil}
+		wg.Wait()
+
+		closing.Lock()
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+		close(out)
+		closing.Unlock()
+
+		h.mu.Lock()
+		h.proxies = append(h.proxies, sent...)
+		h.mu.Unlock()
+	}()
+	return out
+}
 
+// runProvider calls provider with its own deadline and gives up on it as soon as the deadline passes, even if the provider
+// ignores its context
+func (h *Harvester) runProvider(ctx context.Context, provider Provider) ProviderResponse {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
 	}
+	start := time.Now()
+	respStream := make(chan ProviderResponse, 1)
+	go func() {
+		respStream <- provider(ctx)
+	}()
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(kinds))
-	for _, kind := range kinds {
-		go func(kind string, wg *sync.WaitGroup) {
-			defer wg.Done()
-			get(kind)
-		}(kind, &wg)
+	var resp ProviderResponse
+	select {
+	case resp = <-respStream:
+	case <-ctx.Done():
+		resp = ProviderResponse{Proxies: []*Proxy{}, Err: ctx.Err()}
 	}
-	wg.Wait()
-
-	close(respStream)
-	for result := range respStream {
-		proxies = append(proxies, result.Proxies...)
-		if result.Err != nil {
-			resultErr = multierror.Append(resultErr, result.Err)
-		}
+	if resp.Source == "" {
+		resp.Source = providerName(provider)
+	}
+	attribute(resp.Source, resp.Proxies)
+	if h.observer != nil {
+		h.observer.ProviderDone(ProviderReport{Source: resp.Source, Proxies: len(resp.Proxies),
+			Duration: time.Since(start), Err: resp.Err, Rejected: resp.Rejected})
 	}
-	return ProviderResponse{Proxies: proxies, Err: resultErr}
+	return resp
 }
 
-// FateProxyList is a provider which fetches proxies from https://raw.githubusercontent.com/fate0/proxylist
-func FateProxyList() ProviderResponse {
-	parseJSON := func(jsonStr string) []string {
-		type Resp struct {
-			Host string `json:"host"`
-			Port int    `json:"port"`
-		}
-		var proxies []string
-		list := strings.Split(jsonStr, "\n")
-		for _, item := range list {
-			var v Resp
-			itemBytes := []byte(item)
-			if err := json.Unmarshal(itemBytes, &v); err != nil {
-				continue
-			}
-			proxies = append(proxies, v.Host+":"+strconv.Itoa(v.Port))
-		}
-		return proxies
+// providerName returns the name of the function behind provider, a Provider or the function given to ProviderFunc,
+// without its package path
+func providerName(provider interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(provider).Pointer())
+	if fn == nil {
+		return ""
 	}
-	respStream := make(chan ProviderResponse)
-	get := func() {
-		defer close(respStream)
-		var list []*Proxy
-		resp, err := getBody("https://raw.githubusercontent.com/fate0/proxylist/master/proxy.list")
-		if err != nil {
-			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
-			return
-		}
-		proxies := parseJSON(resp)
-		for _, proxy := range proxies {
-			host := New(proxy, "", "")
-			list = append(list, host)
-		}
-		respStream <- ProviderResponse{Proxies: list, Err: nil}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
 	}
-	go func() {
-		get()
-	}()
-	return <-respStream
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
 }
 
-// ClarkTMProxy is a provider which fetches proxies from https://raw.githubusercontent.com/clarketm/proxy-list
-func ClarkTMProxy() ProviderResponse {
-	respStream := make(chan ProviderResponse)
-	parseProxies := func(proxies string) []string {
-		list := strings.Split(proxies, "\n")
-		list = list[4 : len(list)-2]
-		for i, item := range list {
-			list[i] = strings.Fields(item)[0]
-		}
-		return list
+// Proxies returns the list of proxies contained in the Harvester struct
+func (h *Harvester) Proxies() []*Proxy {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.proxies
+}
+
+func getBody(ctx context.Context, site string) (string, error) {
+	client := http.Client{}
+	req, err := http.NewRequestWithContext(ctx, "GET", site, nil)
+	if err != nil {
+		return "", err
 	}
-	get := func() {
-		var proxies []*Proxy
-		defer close(respStream)
-		resp, err := getBody("https://raw.githubusercontent.com/clarketm/proxy-list/master/proxy-list.txt")
-		if err != nil {
-			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
-			return
-		}
-		ips := parseProxies(resp)
-		for _, value := range ips {
-			proxy := New(value, "", "")
-			proxies = append(proxies, proxy)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
 
-		}
-		respStream <- ProviderResponse{Proxies: proxies, Err: nil}
 	}
-	go func() {
-		get()
-	}()
-	return <-respStream
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("groxy: fetching %s: %s", site, resp.Status)
+	}
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(bodyBytes), nil
 }
 
-// MultiProxy is a provider which fetches proxies from http://multiproxy.org/
-func MultiProxy() ProviderResponse {
-	respStream := make(chan ProviderResponse)
-	get := func() {
-		var proxies []*Proxy
-		defer close(respStream)
-		resp, err := getBody("http://multiproxy.org/txt_all/proxy.txt")
-		if err != nil {
-			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
-			return
-		}
-		for _, value := range strings.Split(resp, "\n") {
-			proxy := New(value, "", "")
-			proxies = append(proxies, proxy)
-		}
-		respStream <- ProviderResponse{Proxies: proxies, Err: nil}
+// providers built from source configs, see SourceConfig
+var (
+	proxyListDL = mustProvider(SourceConfig{
+		Name:      "ProxyListDL",
+		URLs:      []string{"https://www.proxy-list.download/api/v1/get?type={protocol}"},
+		Protocols: []Protocol{HTTP, HTTPS, SOCKS4, SOCKS5},
+	})
+	fateProxyList = mustProvider(SourceConfig{
+		Name:   "FateProxyList",
+		URLs:   []string{"https://raw.githubusercontent.com/fate0/proxylist/master/proxy.list"},
+		Format: FormatJSONLines,
+		Fields: SourceFields{Host: "host", Port: "port", Protocol: "type", Declared: map[string]string{
+			AttrAnonymity: "anonymity", AttrCountry: "country", AttrResponseTime: "response_time"}},
+	})
+	multiProxy = mustProvider(SourceConfig{
+		Name: "MultiProxy",
+		URLs: []string{"http://multiproxy.org/txt_all/proxy.txt"},
+	})
+	proxyListNET = mustProvider(SourceConfig{
+		Name: "ProxyListNET",
+		URLs: []string{"http://www.proxylists.net/http.txt", "http://www.proxylists.net/http_highanon.txt"},
+	})
+	freeProxyList = mustProvider(SourceConfig{
+		Name:   "FreeProxyList",
+		URLs:   []string{"https://free-proxy-list.net/"},
+		Format: FormatHTMLTable,
+		Table:  freeProxyListTable,
+	})
+	sslProxies = mustProvider(SourceConfig{
+		Name:   "SSLProxies",
+		URLs:   []string{"https://www.sslproxies.org/"},
+		Format: FormatHTMLTable,
+		Table:  freeProxyListTable,
+	})
+	proxyNova = mustProvider(SourceConfig{
+		Name:   "ProxyNova",
+		URLs:   []string{"https://www.proxynova.com/proxy-server-list/"},
+		Format: FormatHTMLTable,
+		Table: TableOptions{
+			Selector: "tbl_proxy_list",
+			Columns: TableColumns{
+				IP:        TableColumn{Name: "Proxy IP"},
+				Port:      TableColumn{Name: "Proxy Port"},
+				Anonymity: TableColumn{Name: "Anonymity"},
+			},
+		},
+	})
+)
+
+// freeProxyListTable is the table layout shared by free-proxy-list.net and its sister sites
+var freeProxyListTable = TableOptions{
+	Columns: TableColumns{
+		IP:        TableColumn{Name: "IP Address"},
+		Port:      TableColumn{Name: "Port"},
+		Country:   TableColumn{Name: "Code"},
+		Anonymity: TableColumn{Name: "Anonymity"},
+		HTTPS:     TableColumn{Name: "Https"},
+	},
+}
+
+// mustProvider returns the provider of a built-in source config and panics if the config is invalid
+func mustProvider(config SourceConfig) Provider {
+	provider, err := config.Provider()
+	if err != nil {
+		panic(err)
 	}
-	go func() {
-		get()
-	}()
-	return <-respStream
+	return provider
+}
+
+// ProxyListDL is a provider which fetches proxies from https://www.proxy-list.download
+func ProxyListDL(ctx context.Context) ProviderResponse {
+	return proxyListDL(ctx)
+}
+
+// FateProxyList is a provider which fetches proxies from https://raw.githubusercontent.com/fate0/proxylist
+func FateProxyList(ctx context.Context) ProviderResponse {
+	return fateProxyList(ctx)
 }
 
-// SpysME is a provider which fetches proxies from http://spys.me/
-func SpysME() ProviderResponse {
+// ClarkTMProxy is a provider which fetches proxies from https://raw.githubusercontent.com/clarketm/proxy-list
+func ClarkTMProxy(ctx context.Context) ProviderResponse {
 	respStream := make(chan ProviderResponse)
-	parseProxies := func(proxies string) []string {
-		list := strings.Split(proxies, "\n")
-		list = list[4 : len(list)-2]
-		for i, item := range list {
-			list[i] = strings.Fields(item)[0]
-		}
-		return list
-	}
 	get := func() {
-		var proxies []*Proxy
 		defer close(respStream)
-		resp, err := getBody("http://spys.me/proxy.txt")
+		resp, err := getBody(ctx, "https://raw.githubusercontent.com/clarketm/proxy-list/master/proxy-list.txt")
 		if err != nil {
-			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
+			respStream <- ProviderResponse{Source: "ClarkTMProxy", Proxies: []*Proxy{}, Err: err}
 			return
 		}
-		ips := parseProxies(resp)
-		for _, value := range ips {
-			proxy := New(value, "", "")
-
-			proxies = append(proxies, proxy)
-
-		}
-		respStream <- ProviderResponse{Proxies: proxies, Err: nil}
+		proxies, rejected := parseSpysList(resp, HTTP)
+		attribute("ClarkTMProxy", proxies)
+		Emit(ctx, proxies...)
+		respStream <- ProviderResponse{Source: "ClarkTMProxy", Proxies: proxies, Err: nil, Rejected: rejected}
 	}
 	go func() {
 		get()
@@ -246,53 +390,138 @@ func SpysME() ProviderResponse {
 	return <-respStream
 }
 
-// ProxyListNET is a provider which fetches proxies from http://www.proxylists.net/
-func ProxyListNET() ProviderResponse {
+// MultiProxy is a provider which fetches proxies from http://multiproxy.org/
+func MultiProxy(ctx context.Context) ProviderResponse {
+	return multiProxy(ctx)
+}
+
+// SpysME is a provider which fetches http and socks5 proxies from http://spys.me/
+func SpysME(ctx context.Context) ProviderResponse {
 	var resultErr error
 	var proxies []*Proxy
-	kinds := []string{"http", "http_highanon"}
+	var rejected []*ParseError
+	kinds := map[string]Protocol{"proxy": HTTP, "socks": SOCKS5}
 	respStream := make(chan ProviderResponse, len(kinds))
-	get := func(kind string) {
-		var list []*Proxy
-		site := fmt.Sprintf("http://www.proxylists.net/%s.txt", kind)
+	get := func(kind string, protocol Protocol) {
+		site := fmt.Sprintf("http://spys.me/%s.txt", kind)
 
-		bodyString, err := getBody(site)
+		bodyString, err := getBody(ctx, site)
 		if err != nil {
 			respStream <- ProviderResponse{Proxies: []*Proxy{}, Err: err}
 			return
 		}
-
-		for _, ip := range strings.Fields(bodyString) {
-			proxy := New(ip, "", "")
-
-			list = append(list, proxy)
-		}
-		respStream <- ProviderResponse{Proxies: list, Err: nil}
-
+		list, rejected := parseSpysList(bodyString, protocol)
+		attribute("SpysME", list)
+		Emit(ctx, list...)
+		respStream <- ProviderResponse{Proxies: list, Err: nil, Rejected: rejected}
 	}
 
 	wg := sync.WaitGroup{}
 	wg.Add(len(kinds))
-	for _, kind := range kinds {
-		go func(kind string, wg *sync.WaitGroup) {
+	for kind, protocol := range kinds {
+		go func(kind string, protocol Protocol, wg *sync.WaitGroup) {
 			defer wg.Done()
-			get(kind)
-		}(kind, &wg)
+			get(kind, protocol)
+		}(kind, protocol, &wg)
 	}
 	wg.Wait()
 
 	close(respStream)
 	for result := range respStream {
 		proxies = append(proxies, result.Proxies...)
+		rejected = append(rejected, result.Rejected...)
 		if result.Err != nil {
 			resultErr = multierror.Append(resultErr, result.Err)
 		}
+	}
+	return ProviderResponse{Source: "SpysME", Proxies: proxies, Err: resultErr, Rejected: rejected}
+}
 
+// parseSpysList parses the spys.me list format, which is also used by clarketm/proxy-list
+// The list starts with a four line header and ends with a blank line and a footer, each entry looks like
+// "1.2.3.4:8080 US-H-S +" where the flags declare the country, the anonymity and with a trailing S https support
+func parseSpysList(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+	var proxies []*Proxy
+	var rejected []*ParseError
+	list := strings.Split(body, "\n")
+	if len(list) < 6 {
+		return proxies, rejected
 	}
-	return ProviderResponse{Proxies: proxies, Err: resultErr}
+	for i, item := range list[4 : len(list)-2] {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		// the flags are the country, the anonymity letter and an S for https support
+		var flags []string
+		if len(fields) > 1 {
+			flags = strings.Split(strings.TrimSuffix(fields[1], "!"), "-")
+		}
+		https := len(flags) > 2 && flags[2] == "S"
+		kind := protocol
+		if kind == HTTP && https {
+			kind = HTTPS
+		}
+		proxy, err := ParseWithProtocol(kind, fields[0])
+		if err != nil {
+			parseErr := err.(*ParseError)
+			parseErr.Line = i + 5
+			rejected = append(rejected, parseErr)
+			continue
+		}
+		if len(flags) > 1 {
+			proxy.Declare(AttrCountry, flags[0])
+			proxy.Declare(AttrAnonymity, flags[1])
+			if protocol == HTTP {
+				proxy.Declare(AttrHTTPS, strconv.FormatBool(https))
+			}
+		}
+		proxies = append(proxies, proxy.listed(i+5, item))
+	}
+	return proxies, rejected
+}
+
+// FreeProxyList is a provider which scrapes the proxy table of https://free-proxy-list.net/
+func FreeProxyList(ctx context.Context) ProviderResponse {
+	return freeProxyList(ctx)
+}
+
+// SSLProxies is a provider which scrapes the proxy table of https://www.sslproxies.org/
+func SSLProxies(ctx context.Context) ProviderResponse {
+	return sslProxies(ctx)
+}
+
+// ProxyNova is a provider which scrapes the proxy table of https://www.proxynova.com/proxy-server-list/
+func ProxyNova(ctx context.Context) ProviderResponse {
+	return proxyNova(ctx)
+}
+
+// ProxyListNET is a provider which fetches proxies from http://www.proxylists.net/
+func ProxyListNET(ctx context.Context) ProviderResponse {
+	return proxyListNET(ctx)
 }
 
 // WithAllProviders is a simple utility function which is used to pass all provider functions to the NewHarvester constructor
 func WithAllProviders() []Provider {
-	return []Provider{ProxyListNET, SpysME, MultiProxy, ClarkTMProxy, FateProxyList, ProxyListDL}
+	return []Provider{ProxyListNET, SpysME, MultiProxy, ClarkTMProxy, FateProxyList, ProxyListDL, FreeProxyList,
+		SSLProxies, ProxyNova}
+}
+
+// ProviderNames returns the names of the built-in providers, in the order used by WithAllProviders
+func ProviderNames() []string {
+	var names []string
+	for _, provider := range WithAllProviders() {
+		names = append(names, providerName(provider))
+	}
+	return names
+}
+
+// ProviderByName returns the built-in provider with the given name, names are case insensitive
+func ProviderByName(name string) (Provider, error) {
+	for _, provider := range WithAllProviders() {
+		if strings.EqualFold(providerName(provider), name) {
+			return provider, nil
+		}
+	}
+	return nil, fmt.Errorf("groxy: unknown provider %q", name)
 }
@@ -1,8 +1,14 @@
 package groxy
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewHarvester(t *testing.T) {
@@ -39,6 +45,84 @@ func TestHarvester_Harvest(t *testing.T) {
 	}
 }
 
+func TestHarvester_HarvestContext(t *testing.T) {
+	found := func(ctx context.Context) ProviderResponse {
+		return ProviderResponse{Source: "found", Proxies: []*Proxy{New("1.2.3.4:80", "", "")}}
+	}
+	failed := func(ctx context.Context) ProviderResponse {
+		return ProviderResponse{Source: "failed", Err: errors.New("down")}
+	}
+	stuck := func(ctx context.Context) ProviderResponse {
+		<-ctx.Done()
+		return ProviderResponse{Source: "stuck", Err: ctx.Err()}
+	}
+	legacy := ProviderFunc(func() ProviderResponse {
+		return ProviderResponse{Proxies: []*Proxy{New("5.6.7.8:80", "", "")}}
+	})
+	tests := []struct {
+		name        string
+		providers   []Provider
+		wantProxies int
+		wantFailed  int
+	}{
+		{"no providers", nil, 0, 0},
+		{"found and failed", []Provider{found, failed}, 1, 1},
+		{"stuck provider times out", []Provider{found, stuck}, 1, 1},
+		{"provider without context", []Provider{found, legacy}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHarvester(tt.providers...)
+			h.SetTimeout(50 * time.Millisecond)
+			report := h.HarvestContext(context.Background())
+			if got := len(report.Proxies); got != tt.wantProxies {
+				t.Errorf("HarvestContext() proxies = %v, want %v", got, tt.wantProxies)
+			}
+			if got := len(report.Failed()); got != tt.wantFailed {
+				t.Errorf("HarvestContext() failed = %v, want %v", got, tt.wantFailed)
+			}
+			if got := len(report.Providers); got != len(tt.providers) {
+				t.Errorf("HarvestContext() reports = %v, want %v", got, len(tt.providers))
+			}
+		})
+	}
+}
+
+func TestHarvester_HarvestStream(t *testing.T) {
+	release := make(chan struct{})
+	slow := func(ctx context.Context) ProviderResponse {
+		first := New("1.2.3.4:80", "", "")
+		Emit(ctx, first)
+		<-release
+		return ProviderResponse{Source: "slow", Proxies: []*Proxy{first, New("5.6.7.8:80", "", "")}}
+	}
+	duplicate := func(ctx context.Context) ProviderResponse {
+		return ProviderResponse{Source: "duplicate", Proxies: []*Proxy{New("1.2.3.4:80", "", "")}}
+	}
+
+	h := NewHarvester(slow, duplicate)
+	stream := h.HarvestStream(context.Background())
+	select {
+	case proxy := <-stream:
+		if proxy.Host() != "1.2.3.4:80" {
+			t.Errorf("HarvestStream() first = %v, want 1.2.3.4:80", proxy.Host())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HarvestStream() did not send the emitted proxy before its provider returned")
+	}
+	close(release)
+	var hosts []string
+	for proxy := range stream {
+		hosts = append(hosts, proxy.Host())
+	}
+	if want := []string{"5.6.7.8:80"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("HarvestStream() rest = %v, want %v", hosts, want)
+	}
+	if got := len(h.Proxies()); got != 2 {
+		t.Errorf("Proxies() = %v proxies, want 2", got)
+	}
+}
+
 func TestHarvester_Proxies(t *testing.T) {
 	tests := []struct {
 		name string
@@ -57,6 +141,14 @@ func TestHarvester_Proxies(t *testing.T) {
 }
 
 func Test_getBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "gone", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "1.2.3.4:80")
+	}))
+	defer server.Close()
 	type args struct {
 		site string
 	}
@@ -66,11 +158,12 @@ func Test_getBody(t *testing.T) {
 		want    string
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{"ok", args{server.URL + "/list"}, "1.2.3.4:80", false},
+		{"server error", args{server.URL + "/missing"}, "", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getBody(tt.args.site)
+			got, err := getBody(context.Background(), tt.args.site)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getBody() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -91,7 +184,7 @@ func TestProxyListDL(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ProxyListDL(); !reflect.DeepEqual(got, tt.want) {
+			if got := ProxyListDL(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ProxyListDL() = %v, want %v", got, tt.want)
 			}
 		})
@@ -107,7 +200,7 @@ func TestFateProxyList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := FateProxyList(); !reflect.DeepEqual(got, tt.want) {
+			if got := FateProxyList(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("FateProxyList() = %v, want %v", got, tt.want)
 			}
 		})
@@ -123,7 +216,7 @@ func TestClarkTMProxy(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ClarkTMProxy(); !reflect.DeepEqual(got, tt.want) {
+			if got := ClarkTMProxy(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ClarkTMProxy() = %v, want %v", got, tt.want)
 			}
 		})
@@ -139,7 +232,7 @@ func TestMultiProxy(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := MultiProxy(); !reflect.DeepEqual(got, tt.want) {
+			if got := MultiProxy(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("MultiProxy() = %v, want %v", got, tt.want)
 			}
 		})
@@ -155,7 +248,7 @@ func TestSpysME(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := SpysME(); !reflect.DeepEqual(got, tt.want) {
+			if got := SpysME(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("SpysME() = %v, want %v", got, tt.want)
 			}
 		})
@@ -171,7 +264,7 @@ func TestProxyListNET(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ProxyListNET(); !reflect.DeepEqual(got, tt.want) {
+			if got := ProxyListNET(context.Background()); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ProxyListNET() = %v, want %v", got, tt.want)
 			}
 		})
@@ -193,3 +286,55 @@ func TestWithAllProviders(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseSpysList(t *testing.T) {
+	header := "Proxy list\nHttp proxy\nformat\n\n"
+	footer := "\n\nFree proxy list"
+	tests := []struct {
+		name      string
+		body      string
+		protocol  Protocol
+		wantHosts []string
+		wantProto []Protocol
+		wantLines []int
+	}{
+		{"empty body", "", HTTP, nil, nil, nil},
+		{"http and https", header + "1.2.3.4:8080 US-H +\n5.6.7.8:3128 DE-A-S! -" + footer, HTTP,
+			[]string{"1.2.3.4:8080", "5.6.7.8:3128"}, []Protocol{HTTP, HTTPS}, nil},
+		{"socks list keeps protocol", header + "1.2.3.4:1080 US-H-S +" + footer, SOCKS5,
+			[]string{"1.2.3.4:1080"}, []Protocol{SOCKS5}, nil},
+		{"garbage rejected", header + "1.2.3.4:8080 US-H +\nnot-a-proxy\n1.2.3.4:70000 US-H +" + footer, HTTP,
+			[]string{"1.2.3.4:8080"}, []Protocol{HTTP}, []int{6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hosts []string
+			var protocols []Protocol
+			var lines []int
+			proxies, rejected := parseSpysList(tt.body, tt.protocol)
+			for _, proxy := range proxies {
+				hosts = append(hosts, proxy.Host())
+				protocols = append(protocols, proxy.Protocol())
+			}
+			for _, parseErr := range rejected {
+				lines = append(lines, parseErr.Line)
+			}
+			if !reflect.DeepEqual(hosts, tt.wantHosts) || !reflect.DeepEqual(protocols, tt.wantProto) {
+				t.Errorf("parseSpysList() = %v %v, want %v %v", hosts, protocols, tt.wantHosts, tt.wantProto)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("parseSpysList() rejected lines = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestProviderFunc(t *testing.T) {
+	legacy := func() ProviderResponse {
+		return ProviderResponse{Proxies: []*Proxy{New("1.2.3.4:80", "", "")}}
+	}
+	resp := ProviderFunc(legacy)(context.Background())
+	if resp.Source != "TestProviderFunc.func1" || len(resp.Proxies) != 1 {
+		t.Errorf("ProviderFunc() response = %+v, want the proxy named after the function", resp)
+	}
+}
@@ -0,0 +1,153 @@
+package groxy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of checks remembered for each proxy
+const DefaultHistorySize = 50
+
+// DefaultHalfLife is the age at which a check counts half as much as a fresh one when scoring reliability
+const DefaultHalfLife = time.Hour
+
+// scoreConfidence is the number of checks at which a perfect history scores one half, it keeps a proxy which passed a
+// single check from outranking one which passed many
+const scoreConfidence = 3
+
+// CheckRecord is the outcome of a single check of a proxy
+type CheckRecord struct {
+	Time    time.Time
+	Latency time.Duration
+	OK      bool
+	// ErrClass describes why the check failed, it is empty for successful checks
+	ErrClass string
+	// Target is the url the proxy was checked against
+	Target string
+}
+
+// History is a rolling window of the most recent checks of a proxy, it is safe for concurrent use
+type History struct {
+	mu      sync.RWMutex
+	records []CheckRecord
+	next    int
+	size    int
+}
+
+// NewHistory returns an empty history remembering at most size checks
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{size: size}
+}
+
+// Add records a check, the oldest check is forgotten once the history is full
+func (h *History) Add(record CheckRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.records) < h.size {
+		h.records = append(h.records, record)
+		return
+	}
+	h.records[h.next] = record
+	h.next = (h.next + 1) % h.size
+}
+
+// Records returns the remembered checks, oldest first
+func (h *History) Records() []CheckRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	records := make([]CheckRecord, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+	return append(records, h.records[:h.next]...)
+}
+
+// Len returns the number of remembered checks
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.records)
+}
+
+// Last returns the most recent check, ok is false when the history is empty
+func (h *History) Last() (record CheckRecord, ok bool) {
+	records := h.Records()
+	if len(records) == 0 {
+		return CheckRecord{}, false
+	}
+	return records[len(records)-1], true
+}
+
+// Reliability summarises the checks in a history
+type Reliability struct {
+	Checks       int
+	SuccessRatio float64
+	// P50, P90 and P99 are latency percentiles of the successful checks
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	// Score is between 0 and 1, it is the success ratio with older checks weighing less, scaled down for proxies with few
+	// checks so that consistently good proxies rank above lucky ones
+	Score float64
+}
+
+// Reliability computes the reliability of the history at now, a check halfLife old weighs half as much as a fresh one
+func (h *History) Reliability(now time.Time, halfLife time.Duration) Reliability {
+	records := h.Records()
+	r := Reliability{Checks: len(records)}
+	if len(records) == 0 {
+		return r
+	}
+
+	var successes int
+	var weight, weightedSuccess float64
+	var latencies []time.Duration
+	for _, record := range records {
+		w := 1.0
+		if halfLife > 0 {
+			w = math.Pow(0.5, float64(now.Sub(record.Time))/float64(halfLife))
+		}
+		weight += w
+		if record.OK {
+			successes++
+			weightedSuccess += w
+			latencies = append(latencies, record.Latency)
+		}
+	}
+	r.SuccessRatio = float64(successes) / float64(len(records))
+	if weight > 0 {
+		r.Score = weightedSuccess / weight * float64(len(records)) / float64(len(records)+scoreConfidence)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50 = percentile(latencies, 0.50)
+	r.P90 = percentile(latencies, 0.90)
+	r.P99 = percentile(latencies, 0.99)
+	return r
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// errorClass returns a short description of why a check failed, the name of its FailureKind or error when unknown
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if kind := FailureKindOf(err); kind != FailureUnknown {
+		return kind.String()
+	}
+	return "error"
+}
@@ -0,0 +1,86 @@
+package groxy
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHistory_Add(t *testing.T) {
+	h := NewHistory(3)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		h.Add(CheckRecord{Time: base.Add(time.Duration(i) * time.Second), OK: true})
+	}
+	var got []time.Time
+	for _, record := range h.Records() {
+		got = append(got, record.Time)
+	}
+	want := []time.Time{base.Add(2 * time.Second), base.Add(3 * time.Second), base.Add(4 * time.Second)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("History.Records() = %v, want %v", got, want)
+	}
+	if last, ok := h.Last(); !ok || !last.Time.Equal(want[2]) {
+		t.Errorf("History.Last() = %v, %v, want %v", last.Time, ok, want[2])
+	}
+}
+
+func TestHistory_Reliability(t *testing.T) {
+	now := time.Now()
+	ok := func(age time.Duration, latency time.Duration) CheckRecord {
+		return CheckRecord{Time: now.Add(-age), Latency: latency, OK: true}
+	}
+	failed := func(age time.Duration) CheckRecord {
+		return CheckRecord{Time: now.Add(-age), ErrClass: "timeout"}
+	}
+	tests := []struct {
+		name      string
+		records   []CheckRecord
+		wantRatio float64
+		wantP50   time.Duration
+		wantScore float64
+	}{
+		{"empty", nil, 0, 0, 0},
+		{"lucky once", []CheckRecord{ok(0, time.Second)}, 1, time.Second, 0.25},
+		{"consistently good", []CheckRecord{ok(0, time.Second), ok(0, 2*time.Second), ok(0, 3*time.Second)}, 1,
+			2 * time.Second, 0.5},
+		{"old failure weighs less", []CheckRecord{failed(time.Hour), ok(0, time.Second)}, 0.5, time.Second, 4.0 / 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(10)
+			for _, record := range tt.records {
+				h.Add(record)
+			}
+			got := h.Reliability(now, time.Hour)
+			if got.SuccessRatio != tt.wantRatio || got.P50 != tt.wantP50 {
+				t.Errorf("Reliability() = %+v, want ratio %v p50 %v", got, tt.wantRatio, tt.wantP50)
+			}
+			if diff := got.Score - tt.wantScore; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Reliability().Score = %v, want %v", got.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func Test_errorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, "refused"},
+		{"other", errors.New("boom"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorClass(tt.err); got != tt.want {
+				t.Errorf("errorClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package groxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// JudgeReport is the JSON document served by the judge, it describes the request exactly as the judge received it
+type JudgeReport struct {
+	IP      string              `json:"ip"`
+	Origin  string              `json:"origin"`
+	Port    string              `json:"port"`
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Proto   string              `json:"proto"`
+	Headers map[string][]string `json:"headers"`
+	TLS     *JudgeTLS           `json:"tls,omitempty"`
+}
+
+// JudgeTLS describes the tls connection a request was received on
+type JudgeTLS struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	ServerName         string `json:"server_name"`
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+}
+
+// NewJudge returns a proxy judge http handler, it answers every request with a JudgeReport of the client address, all
+// request headers and the tls details of the connection. Host it somewhere reachable by the proxies being checked and
+// pass its url to WithSelfHostedJudge. Forwarding headers are reported as received and never trusted for the client ip
+func NewJudge() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		report := JudgeReport{
+			IP:      host,
+			Origin:  host,
+			Port:    port,
+			Method:  r.Method,
+			URL:     r.URL.String(),
+			Proto:   r.Proto,
+			Headers: map[string][]string(r.Header.Clone()),
+		}
+		if r.Host != "" {
+			report.Headers["Host"] = []string{r.Host}
+		}
+		if r.TLS != nil {
+			report.TLS = &JudgeTLS{
+				Version:            tls.VersionName(r.TLS.Version),
+				CipherSuite:        tls.CipherSuiteName(r.TLS.CipherSuite),
+				ServerName:         r.TLS.ServerName,
+				NegotiatedProtocol: r.TLS.NegotiatedProtocol,
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,71 @@
+package groxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewJudge(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		header  http.Header
+		wantTLS bool
+	}{
+		{"plain http", httptest.NewServer(NewJudge()), http.Header{"Via": {"1.1 squid"}}, false},
+		{"tls", httptest.NewTLSServer(NewJudge()), http.Header{"X-Forwarded-For": {"1.2.3.4"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.server.Close()
+			req, _ := http.NewRequest("GET", tt.server.URL, nil)
+			req.Header = tt.header
+			resp, err := tt.server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			judged := ParseJudgeResponse(string(body))
+			if judged.Origin != "127.0.0.1" {
+				t.Errorf("judge origin = %v, want %v", judged.Origin, "127.0.0.1")
+			}
+			for name := range tt.header {
+				if judged.Headers.Get(name) != tt.header.Get(name) {
+					t.Errorf("judge header %v = %v, want %v", name, judged.Headers.Get(name), tt.header.Get(name))
+				}
+			}
+			if got := strings.Contains(string(body), `"tls"`); got != tt.wantTLS {
+				t.Errorf("judge reported tls = %v, want %v", got, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestManager_selfHostedJudge(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	// the local proxy connects to the judge from the same address as this machine, so it is transparent
+	manager := NewManager(1, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	manager.Add(New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+	for result := range manager.Run(context.Background()) {
+		if result.Err != nil || result.Proxy == nil {
+			t.Fatalf("Run() result = %+v", result)
+		}
+		if !result.Proxy.Alive() {
+			t.Errorf("Proxy.Alive() = false, want true")
+		}
+		if got := result.Proxy.Anonymity(); got != Transparent {
+			t.Errorf("Proxy.Anonymity() = %v, want %v", got, Transparent)
+		}
+	}
+}
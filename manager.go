@@ -1,49 +1,136 @@
 package groxy
 
 import (
-	ctx "context"
+	"context"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gammazero/workerpool"
 )
 
 const ipCheckAddr = `https://ip4.seeip.org`
-const proxyAnonCheckAddr = `http://bot.whatismyipaddress.com`
+const defaultJudgeAddr = `http://httpbin.org/get`
 
 // TestResult represents the result of running a test on the given proxy
 type TestResult struct {
+	// Err is a *CheckError when the proxy failed a check, its Kind tells whether retrying may help, see FailureKindOf
 	Err   error
 	Proxy *Proxy
+	// LeakedHeaders are the headers revealing a proxy which the judge received, see JudgeResponse.LeakedHeaders
+	LeakedHeaders []string
+	// Checks are the outcomes of the checkers in order, the LivenessCheck of the manager unless replaced with
+	// WithCheckers. Checkers after a failed one are not run
+	Checks []CheckOutcome
+	// Targets are the outcomes of checking the target profiles set with WithTargets, in order
+	Targets []CheckOutcome
+	// Timing is the breakdown of the first request sent by the checkers, or of the request of the failed checker, it is
+	// partial when the request failed
+	Timing Timing
 }
 
 // Manager struct controls af the methods used for operating on proxy lists, such as checking validity, response time,
 // sorting, and filtering
 type Manager struct {
-	pool       *workerpool.WorkerPool
+	mu         sync.Mutex
+	maxConn    int
 	timeout    time.Duration
 	queryURL   *url.URL
 	inputs     []*Proxy
-	ctx        ctx.Context
-	done       ctx.CancelFunc
+	cancel     context.CancelFunc
+	unchecked  []*Proxy
 	realIPs    []string
 	userRandom bool
+	judgeURL   string
+	selfJudge  bool
+	checkers   []Checker
+	targets    []Checker
+	geo        *GeoDB
+	observer   Observer
+}
+
+// ManagerOption configures optional behaviour of a Manager, options are passed to NewManager
+type ManagerOption func(*Manager)
+
+// WithJudge sets the proxy judge used to measure the anonymity of proxies, the judge must echo the request headers it
+// receives, see ParseJudgeResponse for the formats understood. The judge should be served over plain http so proxies
+// can add their headers to the request. The default judge is http://httpbin.org/get
+func WithJudge(judgeURL string) ManagerOption {
+	return func(m *Manager) {
+		m.judgeURL = judgeURL
+	}
+}
+
+// WithSelfHostedJudge makes the manager rely on a judge served by NewJudge instead of third party sites, the judge is
+// requested through each proxy to check liveness, anonymity and leaked headers, and requested directly to learn the real
+// ip address of this machine as the judge sees it. The queryURL passed to NewManager is ignored
+func WithSelfHostedJudge(judgeURL string) ManagerOption {
+	return func(m *Manager) {
+		target, err := url.Parse(judgeURL)
+		if err != nil {
+			return
+		}
+		m.judgeURL = judgeURL
+		m.queryURL = target
+		m.userRandom = false
+		m.selfJudge = true
+	}
+}
+
+// WithCheckers replaces the LivenessCheck of the queryURL run on every proxy by checkers, a proxy is alive only when all
+// of them pass. Include a LivenessCheck or StatusCheck to keep checking liveness, each checker gets the manager timeout
+// and their outcomes are recorded in TestResult.Checks. The response time of a proxy is measured on the first request
+// sent by the checkers
+func WithCheckers(checkers ...Checker) ManagerOption {
+	return func(m *Manager) {
+		m.checkers = append(m.checkers, checkers...)
+	}
+}
+
+// WithTargets makes the manager check every live proxy against the target profiles and record whether it is usable for
+// each of them on the proxy, see Proxy.WorksFor. Failing a target does not make a proxy dead
+func WithTargets(profiles ...TargetProfile) ManagerOption {
+	return func(m *Manager) {
+		m.targets = append(m.targets, targetCheckers(profiles)...)
+	}
+}
+
+// WithGeoIP makes the manager look up the location of every proxy it checks in db, see Proxy.Geo
+func WithGeoIP(db *GeoDB) ManagerOption {
+	return func(m *Manager) {
+		m.geo = db
+	}
 }
 
 // NewManager constructs a new manager struct, maxConn set the number of connections too use at at time for checking proxies
 // timeout sets the timeout to be used for connections, queryUrl sets the url to be used for testing proxies
-func NewManager(maxConn int, timeout time.Duration, queryURL string) *Manager {
-	proxyCtx, cancel := ctx.WithCancel(ctx.Background())
-	manager := &Manager{pool: workerpool.New(maxConn), timeout: timeout, inputs: []*Proxy{}, ctx: proxyCtx, done: cancel, realIPs: GetIPs()}
+func NewManager(maxConn int, timeout time.Duration, queryURL string, options ...ManagerOption) *Manager {
+	manager := &Manager{maxConn: maxConn, timeout: timeout, inputs: []*Proxy{}, judgeURL: defaultJudgeAddr}
 	target, err := url.Parse(queryURL)
 	if err != nil || queryURL == "" {
 		manager.userRandom = true
-		return manager
+	} else {
+		manager.queryURL = target
+	}
+	for _, option := range options {
+		option(manager)
+	}
+	if len(manager.checkers) == 0 {
+		target := ""
+		if !manager.userRandom {
+			target = manager.queryURL.String()
+		}
+		manager.checkers = []Checker{LivenessCheck(target)}
+	}
+	if manager.selfJudge {
+		manager.realIPs = judgeIPs(manager.judgeURL)
+	} else {
+		manager.realIPs = GetIPs()
 	}
-	manager.queryURL = target
 	return manager
 }
 
@@ -55,37 +142,48 @@ func randomTarget() string {
 
 }
 
+// Query returns the proxies added to the manager which match q, in the order of q
+func (m *Manager) Query(q Query) []*Proxy {
+	return q.Apply(m.Inputs())
+}
+
 // Distinct removes all duplicate proxies from a list
 func (m *Manager) Distinct(proxies []*Proxy) []*Proxy {
-	keys := make(map[string]bool)
+	return Distinct(proxies)
+}
+
+// Distinct removes all duplicate proxies from a list, proxies are duplicates when they share a host. The sources of the
+// duplicates are merged into the first proxy of each host
+func Distinct(proxies []*Proxy) []*Proxy {
+	keys := make(map[string]*Proxy)
 	var list []*Proxy
 	for _, proxy := range proxies {
-		if _, value := keys[proxy.Host()]; !value {
-			keys[proxy.Host()] = true
-			list = append(list, proxy)
+		if kept, ok := keys[proxy.Host()]; ok {
+			mergeDuplicate(kept, proxy)
+			continue
 		}
+		keys[proxy.Host()] = proxy
+		list = append(list, proxy)
 	}
 	return list
 }
 
-func (m *Manager) doRequest(proxy *Proxy) (*http.Response, error) {
-	var queryURL string
-	if m.userRandom {
-		queryURL = randomTarget()
-	} else {
-		queryURL = m.queryURL.String()
+// checkedRequest returns the timing and target of the first request the checkers sent through a proxy, the timing is
+// the duration of the first check when none was traced
+func checkedRequest(outcomes []CheckOutcome) (Timing, string) {
+	var target string
+	for _, outcome := range outcomes {
+		if outcome.Timing.Total > 0 {
+			return outcome.Timing, outcome.Target
+		}
+		if target == "" {
+			target = outcome.Target
+		}
 	}
-	client := &http.Client{
-		Timeout: m.timeout,
-		Transport: &http.Transport{
-			DisableKeepAlives: true,
-			Proxy:             http.ProxyURL(proxy.ToURL()),
-		},
+	if len(outcomes) == 0 {
+		return Timing{}, target
 	}
-	req, _ := http.NewRequest("GET", queryURL, nil)
-	req.Close = true
-	resp, err := client.Do(req)
-	return resp, err
+	return Timing{Total: outcomes[0].Duration}, target
 }
 
 func getIPV4() string {
@@ -100,7 +198,7 @@ func getIPV4() string {
 		if err != nil {
 			return ""
 		}
-		bodyString = string(bodyBytes)
+		bodyString = strings.TrimSpace(string(bodyBytes))
 	}
 	return bodyString
 }
@@ -117,10 +215,30 @@ func getIPV6() string {
 		if err != nil {
 			return ""
 		}
-		bodyString = string(bodyBytes)
+		bodyString = strings.TrimSpace(string(bodyBytes))
 	}
 	return bodyString
 }
+
+// judgeIPs requests the judge without a proxy and returns the client address it reports
+func judgeIPs(judgeURL string) []string {
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.Get(judgeURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+	origin := ParseJudgeResponse(string(bodyBytes)).Origin
+	if origin == "" {
+		return nil
+	}
+	return []string{origin}
+}
+
 func GetIPs() []string {
 	var r []string
 
@@ -132,90 +250,197 @@ func GetIPs() []string {
 	}
 	return r
 }
-func (m *Manager) isAnon(proxy *Proxy) bool {
 
+// anonymity requests the judge through proxy and classifies the headers it saw, it also returns the headers leaking the
+// use of a proxy. Failures return AnonymityUnknown
+func (m *Manager) anonymity(ctx context.Context, proxy *Proxy) (AnonymityLevel, []string) {
 	client := &http.Client{
-		Timeout: time.Second * 3,
-		Transport: &http.Transport{
-			DisableKeepAlives: true,
-			Proxy:             http.ProxyURL(proxy.ToURL()),
-		},
+		Timeout:   time.Second * 3,
+		Transport: newTransport(proxy),
 	}
-	req, _ := http.NewRequest("GET", proxyAnonCheckAddr, nil)
+	req, _ := http.NewRequest("GET", m.judgeURL, nil)
 	req.Close = true
-	resp, err := client.Do(req)
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
-		return false
+		return AnonymityUnknown, nil
 	}
 	defer resp.Body.Close()
-	var bodyString string
-	if resp.StatusCode == http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return false
-		}
-		bodyString = string(bodyBytes)
+	if resp.StatusCode != http.StatusOK {
+		return AnonymityUnknown, nil
 	}
-
-	for _, ip := range m.realIPs {
-		if ip == bodyString {
-			return false
-		}
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return AnonymityUnknown, nil
 	}
-	return true
+	judged := ParseJudgeResponse(string(bodyBytes))
+	return judged.Anonymity(m.realIPs), judged.LeakedHeaders()
+}
 
+// checkProxy checks proxy and records the outcome on it, nothing is recorded when ctx is done before the check finishes
+func (m *Manager) checkProxy(ctx context.Context, proxy *Proxy) TestResult {
+	start := time.Now()
+	result := m.testProxy(ctx, proxy)
+	if m.observer != nil && ctx.Err() == nil {
+		m.observer.ProxyChecked(result, time.Since(start))
+	}
+	return result
 }
-func (m *Manager) checkProxy(proxy *Proxy) TestResult {
-	var result TestResult
-	resultProxy := &Proxy{}
 
+// testProxy runs the checks of checkProxy
+func (m *Manager) testProxy(ctx context.Context, proxy *Proxy) TestResult {
+	if m.geo != nil {
+		// lookups only fail on corrupt databases, the proxy is checked without its location then
+		m.geo.Enrich(proxy)
+	}
 	t0 := time.Now()
-	resp, err := m.doRequest(proxy)
-	if err != nil {
-		return TestResult{Err: err, Proxy: proxy}
-
+	outcomes := runCheckers(ctx, proxy, m.checkers, m.timeout, true)
+	if ctx.Err() != nil {
+		return TestResult{Err: ctx.Err(), Proxy: proxy, Checks: outcomes}
 	}
-	if resp != nil && resp.Status == "200 OK" {
-		anon := m.isAnon(proxy)
-		resultProxy.responseTime = time.Since(t0)
-		resultProxy.alive = true
-		resultProxy.url = proxy.ToURL()
-		resultProxy.transParent = anon
-		resultProxy.url.User = url.UserPassword(proxy.Username(), proxy.Password())
-		result = TestResult{Err: nil, Proxy: resultProxy}
+	for _, outcome := range outcomes {
+		if !outcome.Passed {
+			failure := checkFailure(outcome.Name, outcome.Err)
+			proxy.setDead()
+			proxy.History().Add(CheckRecord{Time: t0, Latency: time.Since(t0), ErrClass: errorClass(failure),
+				Target: outcome.Target})
+			return TestResult{Err: failure, Proxy: proxy, Checks: outcomes, Timing: outcome.Timing}
+		}
 	}
-	return result
+	timing, target := checkedRequest(outcomes)
+	anonymity, leaked := m.anonymity(ctx, proxy)
+	if ctx.Err() != nil {
+		return TestResult{Err: ctx.Err(), Proxy: proxy, Checks: outcomes}
+	}
+	targets := runCheckers(ctx, proxy, m.targets, m.timeout, false)
+	if ctx.Err() != nil {
+		return TestResult{Err: ctx.Err(), Proxy: proxy, Checks: outcomes, Targets: targets}
+	}
+	now := time.Now()
+	for _, outcome := range targets {
+		proxy.setTarget(outcome, now)
+	}
+	proxy.setAlive(timing, anonymity)
+	proxy.History().Add(CheckRecord{Time: t0, Latency: timing.Total, OK: true, Target: target})
+	return TestResult{Err: nil, Proxy: proxy, LeakedHeaders: leaked, Checks: outcomes, Targets: targets, Timing: timing}
 }
 
 // Add adds a list of proxies to the manager for checking
 func (m *Manager) Add(proxies ...*Proxy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.inputs = m.Distinct(proxies)
 }
 
-// Run starts the proxy checking process
-func (m *Manager) Run() <-chan TestResult {
+// Run starts checking the proxies added to the manager and returns a channel of results which is closed once every
+// proxy was checked or ctx is done. Checks are recorded on the proxies themselves, so Run can be called again to
+// re-check the same proxies once the previous run is finished
+// Cancelling ctx or calling Stop aborts the requests in flight, proxies whose check did not complete are not sent on
+// the channel and can be obtained with Unchecked. Results are not sent once the run is stopped, their proxies are
+// Unchecked as well
+func (m *Manager) Run(ctx context.Context) <-chan TestResult {
+	inputs := m.Inputs()
+	proxies := make(chan *Proxy, len(inputs))
+	for _, proxy := range inputs {
+		proxies <- proxy
+	}
+	close(proxies)
+	return m.run(ctx, proxies)
+}
+
+// RunStream checks proxies as they are received, such as the proxies sent by Harvester.HarvestStream, instead of the
+// proxies added to the manager. The returned channel is closed once proxies is closed and every check finished, or
+// ctx is done. It behaves like Run otherwise, proxies received but not checked before the run stopped are Unchecked
+func (m *Manager) RunStream(ctx context.Context, proxies <-chan *Proxy) <-chan TestResult {
+	return m.run(ctx, proxies)
+}
+
+// run checks the proxies received from proxies with maxConn workers
+func (m *Manager) run(ctx context.Context, proxies <-chan *Proxy) <-chan TestResult {
+	runCtx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.cancel = cancel
+	m.unchecked = nil
+	m.mu.Unlock()
+
 	results := make(chan TestResult)
+	pool := workerpool.New(m.maxConn)
+	check := func(prox *Proxy) func() {
+		return func() {
+			if runCtx.Err() != nil {
+				m.markUnchecked(prox)
+				return
+			}
+			result := m.checkProxy(runCtx, prox)
+			if runCtx.Err() != nil {
+				// a check which passed as the run stopped is not sent either, the proxy is left for Unchecked
+				m.markUnchecked(prox)
+				return
+			}
+			select {
+			case results <- result:
+			case <-runCtx.Done():
+				m.markUnchecked(prox)
+			}
+		}
+	}
 	go func() {
 		defer close(results)
-		for _, proxy := range m.inputs {
-			check := func(prox *Proxy) func() {
-				return func() {
-					results <- m.checkProxy(prox)
+		defer cancel()
+	receive:
+		for {
+			select {
+			case proxy, ok := <-proxies:
+				if !ok {
+					break receive
+				}
+				pool.Submit(check(proxy))
+			case <-runCtx.Done():
+				// proxies already waiting are unchecked, the rest were never received
+				for {
+					select {
+					case proxy, ok := <-proxies:
+						if !ok {
+							break receive
+						}
+						m.markUnchecked(proxy)
+					default:
+						break receive
+					}
 				}
 			}
-			m.pool.Submit(check(proxy))
 		}
-		m.pool.StopWait()
+		pool.StopWait()
 	}()
 
 	return results
 }
 
-// Stop forces the proxy checking process abort
+func (m *Manager) markUnchecked(proxy *Proxy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unchecked = append(m.unchecked, proxy)
+}
+
+// Stop aborts the current run, the checks in flight are cancelled and the queued ones are skipped
 func (m *Manager) Stop() {
-	m.done()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// Unchecked returns the proxies which were left unchecked because the last run was stopped, it is complete once the
+// channel returned by Run is closed
+func (m *Manager) Unchecked() []*Proxy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Proxy(nil), m.unchecked...)
 }
 
+// Inputs returns the proxies added to the manager for checking
 func (m *Manager) Inputs() []*Proxy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.inputs
 }
@@ -0,0 +1,130 @@
+package groxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_Run(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	manager := NewManager(2, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	manager.Add(New(strings.TrimPrefix(upstream.URL, "http://"), "", ""))
+	for run := 0; run < 2; run++ {
+		count := 0
+		for result := range manager.Run(context.Background()) {
+			count++
+			if result.Err != nil || !result.Proxy.Alive() {
+				t.Errorf("run %d: Run() result = %+v", run, result)
+			}
+		}
+		if count != 1 {
+			t.Errorf("run %d: Run() results = %v, want 1", run, count)
+		}
+		if unchecked := manager.Unchecked(); len(unchecked) != 0 {
+			t.Errorf("run %d: Unchecked() = %v, want none", run, unchecked)
+		}
+	}
+}
+
+func TestManager_RunStream(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	manager := NewManager(2, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	proxies := make(chan *Proxy)
+	results := manager.RunStream(context.Background(), proxies)
+	proxies <- New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	select {
+	case result := <-results:
+		if result.Err != nil || !result.Proxy.Alive() {
+			t.Errorf("RunStream() result = %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunStream() did not check a proxy before its input was closed")
+	}
+	close(proxies)
+	for result := range results {
+		t.Errorf("RunStream() unexpected result = %+v", result)
+	}
+}
+
+func TestManager_Stop(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	release := make(chan struct{})
+	defer close(release)
+	var proxies []*Proxy
+	for i := 0; i < 3; i++ {
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer hanging.Close()
+		proxies = append(proxies, New(strings.TrimPrefix(hanging.URL, "http://"), "", ""))
+	}
+
+	manager := NewManager(1, time.Minute, "", WithSelfHostedJudge(judge.URL))
+	manager.Add(proxies...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := manager.Run(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range results {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not stop after its context was cancelled")
+	}
+	if got := len(manager.Unchecked()); got != 3 {
+		t.Errorf("Unchecked() = %v proxies, want 3", got)
+	}
+}
+
+// stoppingObserver stops the run of a manager once a check completed
+type stoppingObserver struct {
+	stop context.CancelFunc
+}
+
+func (o stoppingObserver) ProviderDone(report ProviderReport)                 {}
+func (o stoppingObserver) ProxyUsed(proxy *Proxy, d time.Duration, err error) {}
+func (o stoppingObserver) ProxyChecked(result TestResult, d time.Duration)    { o.stop() }
+
+func TestManager_stoppedAfterCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager := NewManager(1, 5*time.Second, "", WithObserver(stoppingObserver{cancel}), WithCheckers(
+		CheckerFunc("pass", func(ctx context.Context, proxy *Proxy) error { return nil })))
+	// nothing listens on the proxy, so looking up its anonymity fails at once and keeps it unknown
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	proxy := New(l.Addr().String(), "", "")
+	manager.Add(proxy)
+	for result := range manager.Run(ctx) {
+		t.Errorf("Run() result = %+v, want none once the run stopped", result)
+	}
+	if unchecked := manager.Unchecked(); len(unchecked) != 1 || unchecked[0] != proxy {
+		t.Errorf("Unchecked() = %v, want the proxy whose result was not sent", unchecked)
+	}
+}
@@ -0,0 +1,215 @@
+// Package metrics exposes the harvests, checks and proxy use of groxy as Prometheus metrics
+//
+// A Metrics is a groxy.Observer, it is given to the parts of groxy it should watch and served with Handler. The request
+// series of a proxy are only deleted once it leaves a pool given to ObservePool. Every pool whose RoundTripper or
+// Gateway reports to the metrics with SetObserver must be observed as well, the series of the proxies removed from
+// other pools are kept until the process exits:
+//
+//	m := metrics.New()
+//	manager := groxy.NewManager(50, 10*time.Second, "", groxy.WithObserver(m))
+//	harvester.SetObserver(m)
+//	transport.SetObserver(m)
+//	m.ObservePool("default", pool)
+//	http.Handle("/metrics", m.Handler())
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/G5Becks/groxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics collects the groxy metrics on a registry of its own
+type Metrics struct {
+	registry         *prometheus.Registry
+	providerRuns     *prometheus.CounterVec
+	providerProxies  *prometheus.CounterVec
+	providerRejected *prometheus.CounterVec
+	providerDuration *prometheus.HistogramVec
+	checks           *prometheus.CounterVec
+	checkDuration    *prometheus.HistogramVec
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	pools            *poolCollector
+}
+
+// New constructs the metrics registered on a new registry, the go runtime and process metrics are registered as well
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		providerRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "groxy_provider_runs_total",
+			Help: "Provider runs by provider and outcome, success or failure.",
+		}, []string{"provider", "outcome"}),
+		providerProxies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "groxy_provider_proxies_total",
+			Help: "Proxies returned by each provider.",
+		}, []string{"provider"}),
+		providerRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "groxy_provider_rejected_total",
+			Help: "Entries of each provider which could not be parsed as proxies.",
+		}, []string{"provider"}),
+		providerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "groxy_provider_duration_seconds",
+			Help:    "Time each provider took to return.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 11),
+		}, []string{"provider"}),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "groxy_checks_total",
+			Help: "Proxy checks by outcome, alive or dead, and error class of the failed ones.",
+		}, []string{"outcome", "error_class"}),
+		checkDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "groxy_check_duration_seconds",
+			Help:    "Time each proxy check took by outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "groxy_proxy_requests_total",
+			Help: "Requests and tunnels sent through each proxy by outcome, success or failure.",
+		}, []string{"proxy", "outcome"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "groxy_proxy_request_duration_seconds",
+			Help:    "Time until a proxy answered a request or opened a tunnel, by outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		pools: &poolCollector{
+			desc: prometheus.NewDesc("groxy_pool_proxies",
+				"Proxies in each pool by state: alive, dead, unchecked, or failing when their last use failed.",
+				[]string{"pool", "state"}, nil),
+			pools:  make(map[string]*groxy.Pool),
+			hooked: make(map[*groxy.Pool]bool),
+		},
+	}
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.providerRuns, m.providerProxies, m.providerRejected, m.providerDuration,
+		m.checks, m.checkDuration,
+		m.requests, m.requestDuration,
+		m.pools,
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are registered on, other collectors can be added to it
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns an http handler serving the metrics in the Prometheus exposition format, usually on /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObservePool reports the number of proxies of pool in each state under name, observing another pool with the same
+// name replaces it. The request series of the proxies leaving pool are deleted unless another observed pool holds them
+func (m *Metrics) ObservePool(name string, pool *groxy.Pool) {
+	m.pools.mu.Lock()
+	defer m.pools.mu.Unlock()
+	m.pools.pools[name] = pool
+	if !m.pools.hooked[pool] {
+		m.pools.hooked[pool] = true
+		pool.OnRemove(m.proxyRemoved)
+	}
+}
+
+// proxyRemoved deletes the request series of a proxy which left a pool, unless an observed pool still holds its host
+func (m *Metrics) proxyRemoved(proxy *groxy.Proxy) {
+	if m.pools.holds(proxy.Host()) {
+		return
+	}
+	m.requests.DeletePartialMatch(prometheus.Labels{"proxy": proxy.Host()})
+}
+
+// ProviderDone implements groxy.Observer
+func (m *Metrics) ProviderDone(report groxy.ProviderReport) {
+	m.providerRuns.WithLabelValues(report.Source, outcome(report.Err)).Inc()
+	m.providerProxies.WithLabelValues(report.Source).Add(float64(report.Proxies))
+	m.providerRejected.WithLabelValues(report.Source).Add(float64(len(report.Rejected)))
+	m.providerDuration.WithLabelValues(report.Source).Observe(report.Duration.Seconds())
+}
+
+// ProxyChecked implements groxy.Observer
+func (m *Metrics) ProxyChecked(result groxy.TestResult, d time.Duration) {
+	state, class := "alive", ""
+	if result.Err != nil {
+		state, class = "dead", groxy.FailureKindOf(result.Err).String()
+	}
+	m.checks.WithLabelValues(state, class).Inc()
+	m.checkDuration.WithLabelValues(state).Observe(d.Seconds())
+}
+
+// ProxyUsed implements groxy.Observer, the pool of the proxy must be observed with ObservePool, see the package doc
+func (m *Metrics) ProxyUsed(proxy *groxy.Proxy, d time.Duration, err error) {
+	m.requests.WithLabelValues(proxy.Host(), outcome(err)).Inc()
+	m.requestDuration.WithLabelValues(outcome(err)).Observe(d.Seconds())
+}
+
+// outcome returns the outcome label of an operation which returned err
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// poolCollector reports the size of pools by state when the metrics are gathered
+type poolCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	pools  map[string]*groxy.Pool
+	hooked map[*groxy.Pool]bool
+}
+
+// holds returns whether a proxy of host is in one of the observed pools
+func (c *poolCollector) holds(host string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pool := range c.pools {
+		for _, proxy := range pool.Proxies() {
+			if proxy.Host() == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Describe implements prometheus.Collector
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector, a proxy whose last use failed is counted as failing whatever its last check
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, pool := range c.pools {
+		failing := make(map[*groxy.Proxy]bool)
+		for _, proxy := range pool.Failing() {
+			failing[proxy] = true
+		}
+		counts := map[string]int{"alive": 0, "dead": 0, "unchecked": 0, "failing": 0}
+		for _, proxy := range pool.Proxies() {
+			switch {
+			case failing[proxy]:
+				counts["failing"]++
+			case proxy.Alive():
+				counts["alive"]++
+			case proxy.LastChecked().IsZero():
+				counts["unchecked"]++
+			default:
+				counts["dead"]++
+			}
+		}
+		for state, n := range counts {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), name, state)
+		}
+	}
+}
+
+var _ groxy.Observer = (*Metrics)(nil)
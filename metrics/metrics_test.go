@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G5Becks/groxy"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	found := func(ctx context.Context) groxy.ProviderResponse {
+		return groxy.ProviderResponse{Source: "found", Proxies: []*groxy.Proxy{groxy.New("1.2.3.4:80", "", "")}}
+	}
+	failing := func(ctx context.Context) groxy.ProviderResponse {
+		return groxy.ProviderResponse{Source: "failing", Err: errors.New("unreachable")}
+	}
+	harvester := groxy.NewHarvester(found, failing)
+	harvester.SetObserver(m)
+	harvester.HarvestContext(context.Background())
+
+	judge := httptest.NewServer(groxy.NewJudge())
+	defer judge.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	refused := groxy.New(strings.TrimPrefix(closed.URL, "http://"), "", "")
+	manager := groxy.NewManager(1, 5*time.Second, "", groxy.WithSelfHostedJudge(judge.URL), groxy.WithObserver(m))
+	manager.Add(refused)
+	for range manager.Run(context.Background()) {
+	}
+
+	pool := groxy.NewPool(nil, refused, groxy.New("5.6.7.8:80", "", ""),
+		groxy.FromRecord(groxy.Record{Host: "9.9.9.9:80", Alive: true, LastChecked: time.Now()}))
+	pool.SetMaxFailures(0)
+	m.ObservePool("default", pool)
+	transport := groxy.NewRoundTripper(pool)
+	transport.SetRetries(0)
+	transport.SetObserver(m)
+	// round robin starts with the refused proxy
+	req, _ := http.NewRequest("GET", judge.URL, nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() through a refused proxy succeeded")
+	}
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	scrape := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	body := scrape()
+	for _, want := range []string{
+		`groxy_provider_runs_total{outcome="success",provider="found"} 1`,
+		`groxy_provider_runs_total{outcome="failure",provider="failing"} 1`,
+		`groxy_provider_proxies_total{provider="found"} 1`,
+		`groxy_provider_duration_seconds_count{provider="found"} 1`,
+		`groxy_checks_total{error_class="refused",outcome="dead"} 1`,
+		`groxy_check_duration_seconds_count{outcome="dead"} 1`,
+		`groxy_proxy_requests_total{outcome="failure",proxy="` + refused.Host() + `"} 1`,
+		`groxy_pool_proxies{pool="default",state="failing"} 1`,
+		`groxy_pool_proxies{pool="default",state="alive"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+
+	// the series of a proxy are dropped once it leaves the observed pool
+	pool.Remove(refused)
+	if body := scrape(); strings.Contains(body, `proxy="`+refused.Host()+`"`) {
+		t.Errorf("metrics contain the requests of %s after it left the pool", refused.Host())
+	}
+}
@@ -0,0 +1,295 @@
+package groxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of change a Monitor noticed in a proxy
+type EventKind int
+
+const (
+	// EventAlive is sent when a proxy which was dead or never checked passes a check
+	EventAlive EventKind = iota
+	// EventDead is sent when a live proxy fails a check
+	EventDead
+	// EventDegraded is sent when a live proxy answers much slower than it usually does
+	EventDegraded
+	// EventRemoved is sent when a proxy failed too many checks in a row and is no longer monitored
+	EventRemoved
+)
+
+var eventNames = []string{"alive", "dead", "degraded", "removed"}
+
+// String returns the name of the event kind
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventNames) {
+		return "unknown"
+	}
+	return eventNames[k]
+}
+
+// Event is a change in a proxy noticed by a Monitor
+type Event struct {
+	Kind  EventKind
+	Proxy *Proxy
+	// Result is the check which caused the event
+	Result TestResult
+	// Baseline is the median latency of the proxy before the check, it is set for EventDegraded
+	Baseline time.Duration
+	Time     time.Time
+}
+
+// monitored is the schedule of a proxy watched by a Monitor
+type monitored struct {
+	proxy    *Proxy
+	next     time.Time
+	failures int
+	checking bool
+}
+
+// Monitor keeps re-checking a set of proxies with the checks of a Manager. Reliable proxies are checked less often than
+// flaky or new ones, failing proxies are retried with an exponential backoff and removed after too many failures in a
+// row. Every check is recorded in the proxy's history
+type Monitor struct {
+	manager       *Manager
+	mu            sync.Mutex
+	proxies       map[string]*monitored
+	interval      time.Duration
+	maxInterval   time.Duration
+	maxFailures   int
+	degradeFactor float64
+	wake          chan struct{}
+}
+
+// NewMonitor constructs a monitor checking proxies with manager, as many proxies as the manager's maxConn are checked
+// at a time. Proxies are checked every 5 minutes to every hour depending on their reliability, and removed after 5
+// failures in a row
+func NewMonitor(manager *Manager, proxies ...*Proxy) *Monitor {
+	m := &Monitor{
+		manager:       manager,
+		proxies:       make(map[string]*monitored),
+		interval:      5 * time.Minute,
+		maxInterval:   time.Hour,
+		maxFailures:   5,
+		degradeFactor: 2,
+		wake:          make(chan struct{}, 1),
+	}
+	m.Add(proxies...)
+	return m
+}
+
+// SetIntervals sets the shortest and longest time between two checks of a proxy, new and flaky proxies are checked
+// every interval, the most reliable ones every maxInterval. A failing proxy waits interval after its first failure and
+// twice as long after each following one, up to maxInterval. It returns an error when interval is not positive, proxies
+// would be checked again and again otherwise
+func (m *Monitor) SetIntervals(interval, maxInterval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("groxy: monitor interval must be positive, got %v", interval)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	m.interval = interval
+	m.maxInterval = maxInterval
+	return nil
+}
+
+// SetMaxFailures sets after how many failed checks in a row a proxy is removed, 0 never removes proxies
+func (m *Monitor) SetMaxFailures(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxFailures = n
+}
+
+// SetDegradeFactor sets how many times slower than its median latency a proxy must answer to be reported as degraded
+func (m *Monitor) SetDegradeFactor(factor float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradeFactor = factor
+}
+
+// Add starts monitoring proxies, they are checked as soon as possible. Proxies already monitored are ignored
+func (m *Monitor) Add(proxies ...*Proxy) {
+	m.mu.Lock()
+	for _, proxy := range proxies {
+		if _, ok := m.proxies[proxy.Host()]; !ok {
+			m.proxies[proxy.Host()] = &monitored{proxy: proxy}
+		}
+	}
+	m.mu.Unlock()
+	m.notify()
+}
+
+// Remove stops monitoring proxy
+func (m *Monitor) Remove(proxy *Proxy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.proxies, proxy.Host())
+}
+
+// Proxies returns the proxies being monitored
+func (m *Monitor) Proxies() []*Proxy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	proxies := make([]*Proxy, 0, len(m.proxies))
+	for _, entry := range m.proxies {
+		proxies = append(proxies, entry.proxy)
+	}
+	return proxies
+}
+
+// Run checks the proxies whenever they are due until ctx is done and returns a channel of the events noticed. The
+// channel must be drained, checks wait for their events to be received. It is closed once ctx is done and the checks
+// in flight returned
+func (m *Monitor) Run(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		var wg sync.WaitGroup
+		defer close(events)
+		defer wg.Wait()
+		// a manager without connections still checks one proxy at a time, as its runs do
+		maxConn := m.manager.maxConn
+		if maxConn < 1 {
+			maxConn = 1
+		}
+		slots := make(chan struct{}, maxConn)
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			due, wait := m.due(time.Now())
+			for _, entry := range due {
+				wg.Add(1)
+				go func(entry *monitored) {
+					defer wg.Done()
+					select {
+					case slots <- struct{}{}:
+					case <-ctx.Done():
+						m.mu.Lock()
+						entry.checking = false
+						m.mu.Unlock()
+						return
+					}
+					m.check(ctx, entry, events)
+					<-slots
+				}(entry)
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-m.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// due marks the proxies whose check is due at now as being checked and returns them with the time until the next one
+// is due
+func (m *Monitor) due(now time.Time) ([]*monitored, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*monitored
+	wait := m.maxInterval
+	for _, entry := range m.proxies {
+		if entry.checking {
+			continue
+		}
+		if !entry.next.After(now) {
+			entry.checking = true
+			due = append(due, entry)
+			continue
+		}
+		if until := entry.next.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	return due, wait
+}
+
+// check checks a proxy, schedules its next check and sends the events it caused
+func (m *Monitor) check(ctx context.Context, entry *monitored, events chan<- Event) {
+	proxy := entry.proxy
+	wasAlive := proxy.Alive()
+	baseline := proxy.Reliability().P50
+	result := m.manager.checkProxy(ctx, proxy)
+	now := time.Now()
+
+	m.mu.Lock()
+	entry.checking = false
+	if ctx.Err() != nil {
+		m.mu.Unlock()
+		return
+	}
+	var changes []Event
+	if result.Err == nil {
+		entry.failures = 0
+		entry.next = now.Add(m.successInterval(proxy))
+		latency := result.Timing.Total
+		switch {
+		case !wasAlive:
+			changes = append(changes, Event{Kind: EventAlive, Proxy: proxy, Result: result, Time: now})
+		case baseline > 0 && float64(latency) > float64(baseline)*m.degradeFactor:
+			changes = append(changes, Event{Kind: EventDegraded, Proxy: proxy, Result: result, Baseline: baseline,
+				Time: now})
+		}
+	} else {
+		entry.failures++
+		entry.next = now.Add(m.backoff(entry.failures))
+		if wasAlive {
+			changes = append(changes, Event{Kind: EventDead, Proxy: proxy, Result: result, Time: now})
+		}
+		if m.maxFailures > 0 && entry.failures >= m.maxFailures && m.proxies[proxy.Host()] == entry {
+			delete(m.proxies, proxy.Host())
+			changes = append(changes, Event{Kind: EventRemoved, Proxy: proxy, Result: result, Time: now})
+		}
+	}
+	m.mu.Unlock()
+	m.notify()
+
+	for _, event := range changes {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// successInterval returns the time until the next check of a live proxy, longer the more reliable the proxy is
+func (m *Monitor) successInterval(proxy *Proxy) time.Duration {
+	score := proxy.Reliability().Score
+	return m.interval + time.Duration(float64(m.maxInterval-m.interval)*score)
+}
+
+// backoff returns the time until the next check of a proxy which failed its last checks, it doubles with each failure
+func (m *Monitor) backoff(failures int) time.Duration {
+	wait := m.interval
+	for i := 1; i < failures && wait < m.maxInterval; i++ {
+		wait *= 2
+	}
+	if wait > m.maxInterval {
+		wait = m.maxInterval
+	}
+	return wait
+}
+
+// notify wakes Run up to reschedule
+func (m *Monitor) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
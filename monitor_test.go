@@ -0,0 +1,118 @@
+package groxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor_Run(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	closed := httptest.NewServer(nil)
+	closed.Close()
+
+	good := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	bad := New(strings.TrimPrefix(closed.URL, "http://"), "", "")
+	manager := NewManager(2, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	monitor := NewMonitor(manager, good, bad)
+	if err := monitor.SetIntervals(10*time.Millisecond, 50*time.Millisecond); err != nil {
+		t.Fatalf("SetIntervals() error = %v", err)
+	}
+	monitor.SetMaxFailures(3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events := monitor.Run(ctx)
+	want := []struct {
+		kind  EventKind
+		proxy *Proxy
+	}{
+		{EventAlive, good},
+		{EventRemoved, bad},
+	}
+	for _, w := range want {
+		for {
+			event, ok := <-events
+			if !ok {
+				t.Fatalf("Run() closed before %v event of %s", w.kind, w.proxy.Host())
+			}
+			if event.Kind == w.kind && event.Proxy == w.proxy {
+				break
+			}
+		}
+	}
+	if got := bad.History().Reliability(time.Now(), DefaultHalfLife).Checks; got != 3 {
+		t.Errorf("removed proxy checks = %v, want 3", got)
+	}
+	if proxies := monitor.Proxies(); len(proxies) != 1 || proxies[0] != good {
+		t.Errorf("Proxies() = %v, want only the live proxy", hosts(proxies))
+	}
+
+	upstream.Close()
+	for event := range events {
+		if event.Kind == EventDead && event.Proxy == good {
+			cancel()
+		}
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("Run() closed without a dead event")
+	}
+}
+
+func TestMonitor_intervals(t *testing.T) {
+	monitor := NewMonitor(NewManager(1, time.Second, ""))
+	if err := monitor.SetIntervals(time.Minute, 10*time.Minute); err != nil {
+		t.Fatalf("SetIntervals() error = %v", err)
+	}
+	if err := monitor.SetIntervals(0, time.Minute); err == nil {
+		t.Error("SetIntervals() with a zero interval succeeded, want an error")
+	}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{60, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := monitor.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%v) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	reliable := New("1.1.1.1:80", "", "")
+	for i := 0; i < DefaultHistorySize; i++ {
+		reliable.History().Add(CheckRecord{Time: time.Now(), OK: true})
+	}
+	if fresh, got := monitor.successInterval(New("2.2.2.2:80", "", "")), monitor.successInterval(reliable); fresh !=
+		time.Minute || got <= 5*time.Minute {
+		t.Errorf("successInterval() = %v for a new proxy and %v for a reliable one", fresh, got)
+	}
+}
+
+func TestMonitor_RunWithoutConnections(t *testing.T) {
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	bad := New(strings.TrimPrefix(closed.URL, "http://"), "", "")
+	monitor := NewMonitor(NewManager(0, time.Second, ""), bad)
+	monitor.SetMaxFailures(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for event := range monitor.Run(ctx) {
+		if event.Kind == EventRemoved && event.Proxy == bad {
+			cancel()
+		}
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("Run() checked nothing with a manager of 0 connections")
+	}
+}
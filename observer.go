@@ -0,0 +1,39 @@
+package groxy
+
+import "time"
+
+// Observer is told about harvests, checks and requests as they happen, the metrics package implements it with
+// Prometheus collectors. Methods are called from many goroutines at once and should return quickly
+type Observer interface {
+	// ProviderDone is called once a provider returned or was given up on during a harvest
+	ProviderDone(report ProviderReport)
+	// ProxyChecked is called with the result of every check completed by a Manager, d is how long the whole check took
+	ProxyChecked(result TestResult, d time.Duration)
+	// ProxyUsed is called after each attempt to send a request or open a tunnel through a proxy of a Pool, err is nil
+	// when the proxy carried it
+	ProxyUsed(proxy *Proxy, d time.Duration, err error)
+}
+
+// WithObserver makes the manager report every check it completes to observer, including the checks run by a Monitor
+// and the harvests run by a Scheduler using the manager
+func WithObserver(observer Observer) ManagerOption {
+	return func(m *Manager) {
+		m.observer = observer
+	}
+}
+
+// SetObserver makes the harvester report every provider it runs to observer
+func (h *Harvester) SetObserver(observer Observer) {
+	h.observer = observer
+}
+
+// SetObserver makes the round tripper report every request it sends through a proxy to observer
+func (rt *RoundTripper) SetObserver(observer Observer) {
+	rt.observer = observer
+}
+
+// SetObserver makes the gateway report every request and tunnel it sends through a proxy to observer
+func (g *Gateway) SetObserver(observer Observer) {
+	g.observer = observer
+	g.transport.SetObserver(observer)
+}
@@ -0,0 +1,337 @@
+package groxy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPoolEmpty is returned when a pool has no proxies left to choose from
+var ErrPoolEmpty = errors.New("groxy: no healthy proxies in pool")
+
+// Selector chooses which proxy to use next from a list of healthy proxies, the list is never empty
+type Selector interface {
+	Select(proxies []*Proxy) *Proxy
+}
+
+// SelectorFunc is an adapter which allows an ordinary function to be used as a Selector
+type SelectorFunc func(proxies []*Proxy) *Proxy
+
+// Select calls f(proxies)
+func (f SelectorFunc) Select(proxies []*Proxy) *Proxy {
+	return f(proxies)
+}
+
+type roundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+// RoundRobin returns a selector which cycles through the proxies in order
+func RoundRobin() Selector {
+	return &roundRobin{}
+}
+
+func (s *roundRobin) Select(proxies []*Proxy) *Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proxy := proxies[s.next%len(proxies)]
+	s.next++
+	return proxy
+}
+
+type random struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// Random returns a selector which picks a proxy at random
+func Random() Selector {
+	return &random{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *random) Select(proxies []*Proxy) *Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return proxies[s.r.Intn(len(proxies))]
+}
+
+// Fastest returns a selector which picks the proxy with the lowest ResponseTime, proxies which were never timed are
+// only picked when no timed proxy is left
+func Fastest() Selector {
+	return SelectorFunc(func(proxies []*Proxy) *Proxy {
+		var fastest *Proxy
+		for _, proxy := range proxies {
+			if fastest == nil || (proxy.ResponseTime() > 0 &&
+				(fastest.ResponseTime() == 0 || proxy.ResponseTime() < fastest.ResponseTime())) {
+				fastest = proxy
+			}
+		}
+		return fastest
+	})
+}
+
+// MostReliable returns a selector which picks the proxy with the highest reliability score, ties go to the proxy with
+// the lowest median latency
+func MostReliable() Selector {
+	return SelectorFunc(func(proxies []*Proxy) *Proxy {
+		var best *Proxy
+		var bestScore Reliability
+		for _, proxy := range proxies {
+			score := proxy.Reliability()
+			if best == nil || score.Score > bestScore.Score ||
+				(score.Score == bestScore.Score && score.P50 < bestScore.P50) {
+				best, bestScore = proxy, score
+			}
+		}
+		return best
+	})
+}
+
+type leastRecentlyUsed struct {
+	mu       sync.Mutex
+	lastUsed map[*Proxy]time.Time
+}
+
+// LeastRecentlyUsed returns a selector which picks the proxy it has not handed out for the longest time
+func LeastRecentlyUsed() Selector {
+	return &leastRecentlyUsed{lastUsed: make(map[*Proxy]time.Time)}
+}
+
+func (s *leastRecentlyUsed) Select(proxies []*Proxy) *Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldest *Proxy
+	for _, proxy := range proxies {
+		if oldest == nil || s.lastUsed[proxy].Before(s.lastUsed[oldest]) {
+			oldest = proxy
+		}
+	}
+	s.lastUsed[oldest] = time.Now()
+	return oldest
+}
+
+// Pool is a concurrency safe set of healthy proxies, proxies are chosen with a Selector and evicted once they fail too many
+// times in a row
+type Pool struct {
+	mu          sync.Mutex
+	proxies     []*Proxy
+	failures    map[*Proxy]int
+	selector    Selector
+	maxFailures int
+	removals    uint64
+	onRemove    []func(proxy *Proxy)
+}
+
+// NewPool constructs a pool containing proxies, selector decides which proxy is returned by Next, a nil selector uses
+// RoundRobin. Proxies are evicted after 3 consecutive failures, use SetMaxFailures to change this
+func NewPool(selector Selector, proxies ...*Proxy) *Pool {
+	if selector == nil {
+		selector = RoundRobin()
+	}
+	pool := &Pool{failures: make(map[*Proxy]int), selector: selector, maxFailures: 3}
+	pool.Add(proxies...)
+	return pool
+}
+
+// SetMaxFailures sets how many consecutive failures evict a proxy from the pool, zero or less never evicts
+func (p *Pool) SetMaxFailures(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxFailures = n
+}
+
+// Add adds proxies to the pool, proxies already in the pool are ignored
+func (p *Pool) Add(proxies ...*Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, proxy := range proxies {
+		if _, ok := p.failures[proxy]; ok {
+			continue
+		}
+		p.failures[proxy] = 0
+		p.proxies = append(p.proxies, proxy)
+	}
+}
+
+// OnRemove registers fn to be called with each proxy leaving the pool, whether removed, pruned or evicted. fn is kept as
+// long as the pool and is called without the pool locked
+func (p *Pool) OnRemove(fn func(proxy *Proxy)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onRemove = append(p.onRemove, fn)
+}
+
+// Remove removes a proxy from the pool
+func (p *Pool) Remove(proxy *Proxy) {
+	p.mu.Lock()
+	removed := p.remove(proxy)
+	p.mu.Unlock()
+	if removed {
+		p.removed(proxy)
+	}
+}
+
+// removed calls the OnRemove functions with a proxy which left the pool, p.mu must not be held
+func (p *Pool) removed(proxy *Proxy) {
+	p.mu.Lock()
+	listeners := p.onRemove
+	p.mu.Unlock()
+	for _, fn := range listeners {
+		fn(proxy)
+	}
+}
+
+// remove removes proxy and returns whether it was in the pool, p.mu must be held
+func (p *Pool) remove(proxy *Proxy) bool {
+	if _, ok := p.failures[proxy]; !ok {
+		return false
+	}
+	delete(p.failures, proxy)
+	p.removals++
+	for i, item := range p.proxies {
+		if item == proxy {
+			p.proxies = append(p.proxies[:i:i], p.proxies[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// removalCount returns how many proxies left the pool so far, whether removed, pruned or evicted, state kept per proxy
+// outside the pool is swept when it changes
+func (p *Pool) removalCount() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.removals
+}
+
+// Proxies returns a copy of the proxies currently in the pool
+func (p *Pool) Proxies() []*Proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Proxy(nil), p.proxies...)
+}
+
+// Len returns the number of proxies in the pool
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.proxies)
+}
+
+// Next returns the proxy chosen by the pool's selector, or ErrPoolEmpty
+func (p *Pool) Next() (*Proxy, error) {
+	return p.NextExcluding(nil)
+}
+
+// NextExcluding returns the proxy chosen by the pool's selector ignoring the proxies in exclude, it is used to retry a
+// request through a different proxy
+func (p *Pool) NextExcluding(exclude map[*Proxy]bool) (*Proxy, error) {
+	return p.next(func(proxy *Proxy) bool { return !exclude[proxy] })
+}
+
+// NextFor returns the proxy chosen by the pool's selector among the proxies usable for every one of targets, see
+// Proxy.WorksFor. It returns ErrPoolEmpty when none is
+func (p *Pool) NextFor(targets ...string) (*Proxy, error) {
+	return p.next(func(proxy *Proxy) bool { return worksForAll(proxy, targets) })
+}
+
+// NextWhere returns the proxy chosen by the pool's selector among the proxies matching filter, such as the proxies of a
+// country, filter.Sort and filter.Limit are ignored. It returns ErrPoolEmpty when none matches
+func (p *Pool) NextWhere(filter Filter) (*Proxy, error) {
+	return p.next(filter.Match)
+}
+
+// next returns the proxy chosen by the pool's selector among the proxies accepted by accept
+func (p *Pool) next(accept func(*Proxy) bool) (*Proxy, error) {
+	p.mu.Lock()
+	proxies := append([]*Proxy(nil), p.proxies...)
+	p.mu.Unlock()
+
+	var candidates []*Proxy
+	for _, proxy := range proxies {
+		if accept(proxy) {
+			candidates = append(candidates, proxy)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrPoolEmpty
+	}
+	return p.selector.Select(candidates), nil
+}
+
+// Success resets the consecutive failure count of proxy
+func (p *Pool) Success(proxy *Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.failures[proxy]; ok {
+		p.failures[proxy] = 0
+	}
+}
+
+// Failure records a failed use of proxy and returns whether it was evicted from the pool
+func (p *Pool) Failure(proxy *Proxy) bool {
+	p.mu.Lock()
+	evicted := false
+	if count, ok := p.failures[proxy]; ok {
+		count++
+		p.failures[proxy] = count
+		if p.maxFailures > 0 && count >= p.maxFailures {
+			evicted = p.remove(proxy)
+		}
+	}
+	p.mu.Unlock()
+	if evicted {
+		p.removed(proxy)
+	}
+	return evicted
+}
+
+// Failing returns the proxies of the pool whose last use failed, they are evicted once they fail maxFailures times in a
+// row
+func (p *Pool) Failing() []*Proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var failing []*Proxy
+	for _, proxy := range p.proxies {
+		if p.failures[proxy] > 0 {
+			failing = append(failing, proxy)
+		}
+	}
+	return failing
+}
+
+// Prune removes the proxies checked at least minChecks times whose reliability score is below minScore, it returns the
+// proxies removed
+func (p *Pool) Prune(minScore float64, minChecks int) []*Proxy {
+	var pruned []*Proxy
+	for _, proxy := range p.Proxies() {
+		score := proxy.Reliability()
+		if score.Checks >= minChecks && score.Score < minScore {
+			p.Remove(proxy)
+			pruned = append(pruned, proxy)
+		}
+	}
+	return pruned
+}
+
+// SelectorByName returns the selector registered under name, one of roundrobin, random, fastest, reliable or lru
+func SelectorByName(name string) (Selector, error) {
+	switch strings.ToLower(name) {
+	case "", "roundrobin", "round-robin":
+		return RoundRobin(), nil
+	case "random":
+		return Random(), nil
+	case "fastest":
+		return Fastest(), nil
+	case "reliable", "most-reliable":
+		return MostReliable(), nil
+	case "lru", "least-recently-used":
+		return LeastRecentlyUsed(), nil
+	}
+	return nil, fmt.Errorf("groxy: unknown selector %q", name)
+}
@@ -0,0 +1,100 @@
+package groxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectors(t *testing.T) {
+	a, b, c := New("1.1.1.1:80", "", ""), New("2.2.2.2:80", "", ""), New("3.3.3.3:80", "", "")
+	b.responseTime = 20 * time.Millisecond
+	c.responseTime = 10 * time.Millisecond
+	proxies := []*Proxy{a, b, c}
+	tests := []struct {
+		name     string
+		selector Selector
+		want     []*Proxy
+	}{
+		{"round robin", RoundRobin(), []*Proxy{a, b, c, a}},
+		{"fastest skips untimed", Fastest(), []*Proxy{c, c, c, c}},
+		{"least recently used", LeastRecentlyUsed(), []*Proxy{a, b, c, a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*Proxy
+			for range tt.want {
+				got = append(got, tt.selector.Select(proxies))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPool_Failure(t *testing.T) {
+	a, b := New("1.1.1.1:80", "", ""), New("2.2.2.2:80", "", "")
+	tests := []struct {
+		name        string
+		maxFailures int
+		failures    int
+		successAt   int
+		wantEvicted bool
+		wantLen     int
+	}{
+		{"evicted after max failures", 2, 2, -1, true, 1},
+		{"success resets count", 2, 2, 1, false, 2},
+		{"never evicted", 0, 5, -1, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(nil, a, b)
+			pool.SetMaxFailures(tt.maxFailures)
+			evicted := false
+			for i := 0; i < tt.failures; i++ {
+				if i == tt.successAt {
+					pool.Success(a)
+				}
+				evicted = pool.Failure(a) || evicted
+			}
+			if evicted != tt.wantEvicted {
+				t.Errorf("Pool.Failure() evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if pool.Len() != tt.wantLen {
+				t.Errorf("Pool.Len() = %v, want %v", pool.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestPool_OnRemove(t *testing.T) {
+	a, b, c, d := New("1.1.1.1:80", "", ""), New("2.2.2.2:80", "", ""), New("3.3.3.3:80", "", ""),
+		New("4.4.4.4:80", "", "")
+	pool := NewPool(nil, a, b, c)
+	pool.SetMaxFailures(1)
+	var removed []*Proxy
+	pool.OnRemove(func(proxy *Proxy) {
+		// the pool is not locked while listeners run
+		pool.Len()
+		removed = append(removed, proxy)
+	})
+	pool.Remove(a)
+	pool.Remove(d)
+	pool.Failure(b)
+	pool.Prune(1.1, 0)
+	if want := []*Proxy{a, b, c}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("OnRemove() got %v, want %v", hosts(removed), hosts(want))
+	}
+}
+
+func TestPool_NextExcluding(t *testing.T) {
+	a, b := New("1.1.1.1:80", "", ""), New("2.2.2.2:80", "", "")
+	pool := NewPool(nil, a, b)
+	if got, err := pool.NextExcluding(map[*Proxy]bool{a: true}); err != nil || got != b {
+		t.Errorf("Pool.NextExcluding() = %v, %v, want %v", got, err, b)
+	}
+	if _, err := pool.NextExcluding(map[*Proxy]bool{a: true, b: true}); err != ErrPoolEmpty {
+		t.Errorf("Pool.NextExcluding() error = %v, want %v", err, ErrPoolEmpty)
+	}
+}
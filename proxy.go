@@ -6,6 +6,9 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,13 +30,45 @@ func IDFromString(id string) ID {
 	return uuid.MustParse(id)
 }
 
-// Proxy represents an http proxy used for accessing the internet anonymously
+// Protocol is the protocol spoken by a proxy server
+type Protocol string
+
+const (
+	// HTTP is a plain http proxy
+	HTTP Protocol = "http"
+	// HTTPS is an http proxy which supports tunneling https traffic with the CONNECT method
+	HTTPS Protocol = "https"
+	// SOCKS4 is a socks version 4 proxy, hostnames are resolved locally before connecting
+	SOCKS4 Protocol = "socks4"
+	// SOCKS4A is a socks version 4a proxy, hostnames are resolved by the proxy
+	SOCKS4A Protocol = "socks4a"
+	// SOCKS5 is a socks version 5 proxy, optionally using username/password authentication
+	SOCKS5 Protocol = "socks5"
+)
+
+// IsSOCKS returns whether the protocol is one of the socks versions
+func (p Protocol) IsSOCKS() bool {
+	return p == SOCKS4 || p == SOCKS4A || p == SOCKS5
+}
+
+// Proxy represents an http or socks proxy used for accessing the internet anonymously
 type Proxy struct {
+	mu           sync.RWMutex
 	id           ID
 	url          *url.URL
 	responseTime time.Duration
-	transParent  bool
+	anonymity    AnonymityLevel
 	alive        bool
+	lastChecked  time.Time
+	country      string
+	history      *History
+	targets      map[string]TargetStatus
+	timing       Timing
+	tags         map[string]bool
+	geo          Geo
+	sightings    map[string]Sighting
+	sources      []SourceRef
+	declared     map[string]string
 }
 
 func (h *Proxy) Id() string {
@@ -51,6 +86,14 @@ func (h *Proxy) Scheme() string {
 	return ""
 }
 
+// Protocol returns the protocol used to talk to the proxy, proxies without a known protocol are assumed to be HTTP
+func (h *Proxy) Protocol() Protocol {
+	if h.url != nil && h.url.Scheme != "" {
+		return Protocol(h.url.Scheme)
+	}
+	return HTTP
+}
+
 // Host returns the host portion of the proxy as a string
 func (h *Proxy) Host() string {
 	if h.url != nil {
@@ -59,14 +102,22 @@ func (h *Proxy) Host() string {
 	return ""
 }
 
+// IsAnon returns whether the proxy hides the client ip address, it is true for anonymous and elite proxies
 func (h *Proxy) IsAnon() bool {
-	return h.transParent
+	return h.Anonymity() >= Anonymous
+}
+
+// Anonymity returns the anonymity level measured when the proxy was checked
+func (h *Proxy) Anonymity() AnonymityLevel {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.anonymity
 }
 
 // Username returns the username portion of the proxy if present
 func (h *Proxy) Username() string {
 	if h.url != nil {
-		return h.url.User.String()
+		return h.url.User.Username()
 	}
 	return ""
 }
@@ -82,14 +133,117 @@ func (h *Proxy) Password() string {
 
 // Alive returns whether or not the proxy is dead
 func (h *Proxy) Alive() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.alive
 }
 
 // ResponseTime returns the proxy response time
 func (h *Proxy) ResponseTime() time.Duration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	return h.responseTime
 }
 
+// LastChecked returns when the proxy was last checked, it is zero for proxies which were never checked
+func (h *Proxy) LastChecked() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lastChecked
+}
+
+// Country returns the ISO 3166 country code of the proxy if known
+func (h *Proxy) Country() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.country
+}
+
+// History returns the rolling history of checks of the proxy
+func (h *Proxy) History() *History {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.history == nil {
+		h.history = NewHistory(DefaultHistorySize)
+	}
+	return h.history
+}
+
+// Reliability returns the reliability of the proxy computed from its history of checks
+func (h *Proxy) Reliability() Reliability {
+	return h.History().Reliability(time.Now(), DefaultHalfLife)
+}
+
+// Tags returns the labels attached to the proxy, sorted
+func (h *Proxy) Tags() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var tags []string
+	for tag := range h.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// HasTag returns whether tag is attached to the proxy
+func (h *Proxy) HasTag(tag string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.tags[tag]
+}
+
+// AddTags attaches labels, such as the name of the list a proxy came from, to the proxy so it can be selected by them,
+// see Filter.Tags
+func (h *Proxy) AddTags(tags ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if h.tags == nil {
+			h.tags = make(map[string]bool)
+		}
+		h.tags[tag] = true
+	}
+}
+
+// RemoveTags detaches labels from the proxy
+func (h *Proxy) RemoveTags(tags ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tag := range tags {
+		delete(h.tags, strings.TrimSpace(tag))
+	}
+}
+
+// Timing returns the breakdown of the request of the last successful check of the proxy, its Total is the ResponseTime
+func (h *Proxy) Timing() Timing {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.timing
+}
+
+// setAlive records a successful check of the proxy
+func (h *Proxy) setAlive(timing Timing, anonymity AnonymityLevel) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alive = true
+	h.responseTime = timing.Total
+	h.timing = timing
+	h.anonymity = anonymity
+	h.lastChecked = time.Now()
+}
+
+// setDead records a failed check of the proxy
+func (h *Proxy) setDead() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alive = false
+	h.lastChecked = time.Now()
+}
+
 // ToURL converts the proxy to a *url.URL
 func (h *Proxy) ToURL() *url.URL {
 	return h.url
@@ -97,24 +251,40 @@ func (h *Proxy) ToURL() *url.URL {
 
 // AsCSV converts the proxy to csv format for saving to disk
 func (h *Proxy) AsCSV() []string {
-	return []string{h.Host(), h.Username(), h.Password()}
+	return []string{h.Host(), h.Username(), h.Password(), string(h.Protocol())}
 }
 
-// New returns a pointer to a proxy, if the url provided cannot be parsed it returns an error
+// New returns a pointer to a proxy for the host:port uri, uri is used as is, use Parse to validate untrusted input
+// A username without a password is kept, it authenticates with an empty password
 func New(uri string, username string, password string) *Proxy {
 	url2 := &url.URL{Host: uri}
 	if len(username) > 0 && len(password) > 0 {
 		url2.User = url.UserPassword(username, password)
+	} else if len(username) > 0 {
+		url2.User = url.User(username)
 	}
 	proxy := Proxy{id: NewID(), url: url2}
 	return &proxy
 
 }
 
+// NewWithProtocol returns a pointer to a proxy which speaks the given protocol
+// SOCKS4 proxies have no password, the username is sent as the socks user id
+func NewWithProtocol(protocol Protocol, uri string, username string, password string) *Proxy {
+	proxy := New(uri, username, password)
+	if protocol == SOCKS4 || protocol == SOCKS4A {
+		if len(username) > 0 {
+			proxy.url.User = url.User(username)
+		}
+	}
+	proxy.url.Scheme = string(protocol)
+	return proxy
+}
+
 // SaveToFile saves a list of proxies to a CSV file
 func SaveToFile(file string, proxies []*Proxy) error {
 	var result error
-	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0666)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
@@ -130,7 +300,9 @@ func SaveToFile(file string, proxies []*Proxy) error {
 	return result
 }
 
-// FromFile loads a list of proxies from a file on disk, it returns an error if there is a problem parsing the file
+// FromFile loads a list of proxies from a csv file on disk, each row is a proxy followed by an optional username,
+// password and protocol. Proxies are read with ParseWithProtocol, rows which cannot be parsed are skipped and returned
+// in a multierror of *ParseError alongside the valid proxies. Other errors reading the file return no proxies
 func FromFile(file string) ([]*Proxy, error) {
 	var result error
 	f, err := os.Open(file)
@@ -148,21 +320,28 @@ func FromFile(file string) ([]*Proxy, error) {
 		} else if err != nil {
 			return []*Proxy{}, err
 		}
-		switch len(line) {
-		case 0:
+		if len(line) == 0 || strings.TrimSpace(line[0]) == "" {
 			continue
-		case 1:
-			proxy := New(line[0], "", "")
-			proxies = append(proxies, proxy)
-		case 2:
-			proxy := New(line[0], line[1], "")
-			proxies = append(proxies, proxy)
-
-		case 3:
-			proxy := New(line[0], line[1], line[2])
-			proxies = append(proxies, proxy)
 		}
-
+		protocol := HTTP
+		if len(line) > 3 && line[3] != "" {
+			protocol = Protocol(strings.ToLower(line[3]))
+		}
+		row, _ := reader.FieldPos(0)
+		proxy, err := ParseWithProtocol(protocol, line[0])
+		if err != nil {
+			parseErr := err.(*ParseError)
+			parseErr.Line = row
+			result = multierror.Append(result, parseErr)
+			continue
+		}
+		if len(line) > 2 {
+			proxy = withCredentials(proxy, line[1], line[2])
+		} else if len(line) > 1 {
+			proxy = withCredentials(proxy, line[1], "")
+		}
+		proxy.addSources(SourceRef{Provider: file, Line: row, Raw: strings.Join(line, ",")})
+		proxies = append(proxies, proxy)
 	}
 
 	return proxies, result
@@ -179,6 +358,121 @@ func FromExisting(
 	alive bool) *Proxy {
 	uri := &url.URL{Host: host}
 	uri.User = url.UserPassword(username, password)
-	return &Proxy{id: IDFromString(id), url: uri, transParent: anon, responseTime: responseTime, alive: alive}
+	anonymity := Transparent
+	if anon {
+		anonymity = Anonymous
+	}
+	return &Proxy{id: IDFromString(id), url: uri, anonymity: anonymity, responseTime: responseTime, alive: alive}
+
+}
 
+// Record is a plain snapshot of a proxy, it is used by storage backends to persist proxies and restore them with
+// FromRecord
+type Record struct {
+	ID           string
+	Protocol     Protocol
+	Host         string
+	Username     string
+	Password     string
+	Anonymity    AnonymityLevel
+	ResponseTime time.Duration
+	Alive        bool
+	LastChecked  time.Time
+	Country      string
+	History      []CheckRecord
+	Targets      map[string]TargetStatus
+	Tags         []string
+	City         string
+	ASN          uint
+	Org          string
+	Sightings    []Sighting
+	Sources      []SourceRef
+	// Declared are the attributes claimed by the sources of the proxy, see Proxy.Declare
+	Declared map[string]string
+	// Timing is the breakdown of ResponseTime, its Total is ignored by FromRecord in favour of ResponseTime
+	Timing Timing
+}
+
+// Record returns a snapshot of the proxy
+func (h *Proxy) Record() Record {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var history []CheckRecord
+	if h.history != nil {
+		history = h.history.Records()
+	}
+	var targets map[string]TargetStatus
+	if len(h.targets) > 0 {
+		targets = make(map[string]TargetStatus, len(h.targets))
+		for name, status := range h.targets {
+			targets[name] = status
+		}
+	}
+	var declared map[string]string
+	if len(h.declared) > 0 {
+		declared = make(map[string]string, len(h.declared))
+		for name, value := range h.declared {
+			declared[name] = value
+		}
+	}
+	var tags []string
+	for tag := range h.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return Record{
+		ID:           h.id.String(),
+		Protocol:     h.Protocol(),
+		Host:         h.Host(),
+		Username:     h.Username(),
+		Password:     h.Password(),
+		Anonymity:    h.anonymity,
+		ResponseTime: h.responseTime,
+		Alive:        h.alive,
+		LastChecked:  h.lastChecked,
+		Country:      h.country,
+		History:      history,
+		Targets:      targets,
+		Tags:         tags,
+		City:         h.geo.City,
+		ASN:          h.geo.ASN,
+		Org:          h.geo.Org,
+		Sightings:    h.sightingList(),
+		Sources:      append([]SourceRef(nil), h.sources...),
+		Declared:     declared,
+		Timing:       h.timing,
+	}
+}
+
+// FromRecord returns a proxy restored from a snapshot taken with Record
+func FromRecord(r Record) *Proxy {
+	proxy := NewWithProtocol(r.Protocol, r.Host, r.Username, r.Password)
+	proxy.id = IDFromString(r.ID)
+	proxy.anonymity = r.Anonymity
+	proxy.responseTime = r.ResponseTime
+	proxy.timing = r.Timing
+	proxy.timing.Total = r.ResponseTime
+	proxy.alive = r.Alive
+	proxy.lastChecked = r.LastChecked
+	proxy.country = r.Country
+	proxy.geo = Geo{City: r.City, ASN: r.ASN, Org: r.Org}
+	proxy.history = NewHistory(DefaultHistorySize)
+	for _, record := range r.History {
+		proxy.history.Add(record)
+	}
+	for name, status := range r.Targets {
+		if proxy.targets == nil {
+			proxy.targets = make(map[string]TargetStatus)
+		}
+		proxy.targets[name] = status
+	}
+	proxy.AddTags(r.Tags...)
+	for _, sighting := range r.Sightings {
+		proxy.addSighting(sighting)
+	}
+	proxy.addSources(r.Sources...)
+	for name, value := range r.Declared {
+		proxy.Declare(name, value)
+	}
+	return proxy
 }
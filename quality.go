@@ -0,0 +1,161 @@
+package groxy
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// SourceRef is where a proxy was listed, Raw is the entry of the list as published and Line its one based line. Line is
+// zero for formats without lines, such as html tables and regex matches
+type SourceRef struct {
+	Provider string
+	Line     int
+	Raw      string
+}
+
+// Sources returns where the proxy was listed in the order the providers were found. Only the latest line of each
+// provider is kept, a proxy listed twice by one source has the later line only. Harvested proxies are attributed to
+// the Source of their provider and proxies read with FromFile to the file
+func (h *Proxy) Sources() []SourceRef {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]SourceRef(nil), h.sources...)
+}
+
+// Providers returns the distinct providers which listed the proxy, sorted by name
+func (h *Proxy) Providers() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.providerList()
+}
+
+// providerList returns the distinct providers of the sources sorted by name, h.mu must be held
+func (h *Proxy) providerList() []string {
+	seen := make(map[string]bool)
+	var providers []string
+	for _, ref := range h.sources {
+		if ref.Provider != "" && !seen[ref.Provider] {
+			seen[ref.Provider] = true
+			providers = append(providers, ref.Provider)
+		}
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// addSources records refs, a ref replaces the one of the same provider so a proxy listed again and again by a provider
+// keeps a single source, the latest line. Earlier lines of the provider are dropped
+func (h *Proxy) addSources(refs ...SourceRef) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ref := range refs {
+		known := false
+		for i, source := range h.sources {
+			if source.Provider == ref.Provider {
+				h.sources[i] = ref
+				known = true
+				break
+			}
+		}
+		if !known {
+			h.sources = append(h.sources, ref)
+		}
+	}
+}
+
+// listed records that the proxy was read from line of a list, the provider is set once the list is attributed
+func (h *Proxy) listed(line int, raw string) *Proxy {
+	h.addSources(SourceRef{Line: line, Raw: strings.TrimSpace(raw)})
+	return h
+}
+
+// attribute sets provider on the sources of proxies which have none yet, proxies without any source are attributed to
+// provider as a whole
+func attribute(provider string, proxies []*Proxy) {
+	for _, proxy := range proxies {
+		proxy.mu.Lock()
+		if len(proxy.sources) == 0 {
+			proxy.sources = append(proxy.sources, SourceRef{Provider: provider})
+		}
+		for i := range proxy.sources {
+			if proxy.sources[i].Provider == "" {
+				proxy.sources[i].Provider = provider
+			}
+		}
+		proxy.mu.Unlock()
+	}
+}
+
+// mergeDuplicate records the sources and declared attributes of duplicate on proxy, it is used when the same host is
+// listed more than once
+func mergeDuplicate(proxy, duplicate *Proxy) {
+	if proxy != duplicate {
+		proxy.addSources(duplicate.Sources()...)
+		mergeDeclared(proxy, duplicate)
+	}
+}
+
+// SourceQuality is how the proxies listed by a provider fared in their last check, it tells the providers worth
+// harvesting from the ones only listing dead or transparent proxies
+type SourceQuality struct {
+	Provider string
+	// Proxies is the number of distinct proxies listed, Checked the number of them checked at least once
+	Proxies int
+	Checked int
+	// Alive, Anonymous and Fast count the proxies which passed their last check, the ones among them hiding the client
+	// address and the ones answering within the fast threshold of QualityReport
+	Alive     int
+	Anonymous int
+	Fast      int
+}
+
+// AliveRatio returns the share of the checked proxies which are alive, it is zero when none was checked
+func (q SourceQuality) AliveRatio() float64 {
+	if q.Checked == 0 {
+		return 0
+	}
+	return float64(q.Alive) / float64(q.Checked)
+}
+
+// QualityReport returns the quality of each provider which listed one of proxies sorted by provider, a proxy listed by
+// several providers counts for each of them. Live proxies answering within fast are counted as fast
+func QualityReport(proxies []*Proxy, fast time.Duration) []SourceQuality {
+	qualities := make(map[string]*SourceQuality)
+	for _, proxy := range proxies {
+		proxy.mu.RLock()
+		providers := proxy.providerList()
+		checked := !proxy.lastChecked.IsZero()
+		alive := proxy.alive
+		anonymous := proxy.anonymity >= Anonymous
+		responseTime := proxy.responseTime
+		proxy.mu.RUnlock()
+		for _, provider := range providers {
+			quality, ok := qualities[provider]
+			if !ok {
+				quality = &SourceQuality{Provider: provider}
+				qualities[provider] = quality
+			}
+			quality.Proxies++
+			if checked {
+				quality.Checked++
+			}
+			if !alive {
+				continue
+			}
+			quality.Alive++
+			if anonymous {
+				quality.Anonymous++
+			}
+			if responseTime <= fast {
+				quality.Fast++
+			}
+		}
+	}
+	report := make([]SourceQuality, 0, len(qualities))
+	for _, quality := range qualities {
+		report = append(report, *quality)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Provider < report[j].Provider })
+	return report
+}
@@ -0,0 +1,80 @@
+package groxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQualityReport(t *testing.T) {
+	listed := func(host string) *Proxy {
+		return New(host, "", "").listed(1, host)
+	}
+	junk := func(ctx context.Context) ProviderResponse {
+		return ProviderResponse{Source: "junk", Proxies: []*Proxy{listed("1.1.1.1:80"), listed("2.2.2.2:80"),
+			listed("3.3.3.3:80")}}
+	}
+	good := func(ctx context.Context) ProviderResponse {
+		// proxies without a line of their own are attributed to the provider as a whole
+		return ProviderResponse{Source: "good", Proxies: []*Proxy{New("3.3.3.3:80", "", ""), New("4.4.4.4:80", "", "")}}
+	}
+	report := NewHarvester(junk, good).HarvestContext(context.Background())
+	proxies := Distinct(report.Proxies)
+	if len(proxies) != 4 {
+		t.Fatalf("Distinct() = %v, want 4 proxies", hosts(proxies))
+	}
+	shared := proxies[2]
+	if want := []SourceRef{{"junk", 1, "3.3.3.3:80"}, {"good", 0, ""}}; !reflect.DeepEqual(shared.Sources(), want) {
+		t.Errorf("Sources() = %+v, want %+v", shared.Sources(), want)
+	}
+	if want := []string{"good", "junk"}; !reflect.DeepEqual(shared.Providers(), want) {
+		t.Errorf("Providers() = %v, want %v", shared.Providers(), want)
+	}
+
+	proxies[0].setDead()
+	proxies[1].setAlive(Timing{Total: 5 * time.Second}, Transparent)
+	shared.setAlive(Timing{Total: 500 * time.Millisecond}, Elite)
+	proxies[3].setAlive(Timing{Total: time.Second}, Anonymous)
+	got := QualityReport(proxies, time.Second)
+	want := []SourceQuality{
+		{Provider: "good", Proxies: 2, Checked: 2, Alive: 2, Anonymous: 2, Fast: 2},
+		{Provider: "junk", Proxies: 3, Checked: 3, Alive: 2, Anonymous: 1, Fast: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QualityReport() = %+v, want %+v", got, want)
+	}
+	if ratio := got[1].AliveRatio(); ratio != 2.0/3 {
+		t.Errorf("AliveRatio() = %v, want %v", ratio, 2.0/3)
+	}
+	if restored := FromRecord(shared.Record()); !reflect.DeepEqual(restored.Sources(), shared.Sources()) {
+		t.Errorf("FromRecord().Sources() = %+v, want %+v", restored.Sources(), shared.Sources())
+	}
+}
+
+func TestProxy_addSources(t *testing.T) {
+	proxy := New("1.1.1.1:80", "", "")
+	for i := 1; i <= 100; i++ {
+		proxy.addSources(SourceRef{Provider: "spys", Line: i, Raw: "1.1.1.1:80"})
+	}
+	proxy.addSources(SourceRef{Provider: "fate0", Line: 3})
+	proxy.addSources(SourceRef{Provider: "spys", Line: 7, Raw: "1.1.1.1:80 US-N"})
+	want := []SourceRef{{"spys", 7, "1.1.1.1:80 US-N"}, {"fate0", 3, ""}}
+	if !reflect.DeepEqual(proxy.Sources(), want) {
+		t.Errorf("Sources() = %+v, want %+v", proxy.Sources(), want)
+	}
+}
+
+func TestDistinct_listedTwiceBySource(t *testing.T) {
+	proxies, _ := parseLines(HTTP, []string{"1.1.1.1:80", "2.2.2.2:80", "http://1.1.1.1:80"})
+	attribute("spys", proxies)
+	proxies = Distinct(proxies)
+	if len(proxies) != 2 {
+		t.Fatalf("Distinct() = %v, want 2 proxies", proxies)
+	}
+	// only the later line of the source is kept
+	want := []SourceRef{{"spys", 3, "http://1.1.1.1:80"}}
+	if !reflect.DeepEqual(proxies[0].Sources(), want) {
+		t.Errorf("Sources() = %+v, want %+v", proxies[0].Sources(), want)
+	}
+}
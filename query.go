@@ -0,0 +1,260 @@
+package groxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortKey is an order proxies can be sorted in, every key puts the best proxies first
+type SortKey int
+
+const (
+	// SortLatency sorts the fastest proxies first, proxies which were never timed go last
+	SortLatency SortKey = iota + 1
+	// SortScore sorts the most reliable proxies first, see Reliability
+	SortScore
+	// SortLastChecked sorts the most recently checked proxies first, proxies never checked go last
+	SortLastChecked
+	// SortDeclared sorts the proxies their sources claim are the most anonymous first, https proxies first among equals.
+	// It orders unchecked proxies so the most promising are checked first, see Proxy.Declared
+	SortDeclared
+)
+
+var sortKeyNames = map[SortKey]string{SortLatency: "latency", SortScore: "score", SortLastChecked: "last-checked",
+	SortDeclared: "declared"}
+
+// String returns the name of the sort key
+func (k SortKey) String() string {
+	if name, ok := sortKeyNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSortKey returns the sort key named by s, one of latency, score, last-checked or declared
+func ParseSortKey(s string) (SortKey, error) {
+	for key, name := range sortKeyNames {
+		if strings.EqualFold(s, name) {
+			return key, nil
+		}
+	}
+	return 0, fmt.Errorf("groxy: unknown sort key %q", s)
+}
+
+// Query builds a Filter one condition at a time, for example NewQuery().Alive().Protocols(SOCKS5).SortBy(SortScore).
+// Limit(10). Queries are values, every method returns a new query so a base query can be refined in several ways.
+// Apply runs it on a list in memory and Find on a Store
+type Query struct {
+	filter Filter
+}
+
+// NewQuery returns a query matching every proxy
+func NewQuery() Query {
+	return Query{}
+}
+
+// QueryFrom returns a query starting from filter
+func QueryFrom(filter Filter) Query {
+	return Query{filter: filter.clone()}
+}
+
+// Alive matches the proxies which passed their last check
+func (q Query) Alive() Query {
+	alive := true
+	q.filter.Alive = &alive
+	return q
+}
+
+// Dead matches the proxies which failed their last check or were never checked
+func (q Query) Dead() Query {
+	alive := false
+	q.filter.Alive = &alive
+	return q
+}
+
+// MinAnonymity matches the proxies at least as anonymous as level
+func (q Query) MinAnonymity(level AnonymityLevel) Query {
+	q.filter.MinAnonymity = level
+	return q
+}
+
+// Protocols matches the proxies speaking any of protocols
+func (q Query) Protocols(protocols ...Protocol) Query {
+	q.filter.Protocols = append(append([]Protocol(nil), q.filter.Protocols...), protocols...)
+	return q
+}
+
+// MaxLatency matches the proxies whose ResponseTime is no greater than max
+func (q Query) MaxLatency(max time.Duration) Query {
+	q.filter.MaxLatency = max
+	return q
+}
+
+// MaxTiming matches the proxies whose Timing phases are no longer than the non zero phases of max
+func (q Query) MaxTiming(max Timing) Query {
+	q.filter.MaxTiming = max
+	return q
+}
+
+// Countries matches the proxies located in any of countries
+func (q Query) Countries(countries ...string) Query {
+	q.filter.Countries = append(append([]string(nil), q.filter.Countries...), countries...)
+	return q
+}
+
+// ASNs matches the proxies whose address is announced by any of asns
+func (q Query) ASNs(asns ...uint) Query {
+	q.filter.ASNs = append(append([]uint(nil), q.filter.ASNs...), asns...)
+	return q
+}
+
+// ExcludeASNs drops the proxies whose address is announced by any of asns
+func (q Query) ExcludeASNs(asns ...uint) Query {
+	q.filter.ExcludeASNs = append(append([]uint(nil), q.filter.ExcludeASNs...), asns...)
+	return q
+}
+
+// MinScore matches the proxies whose reliability score is at least score
+func (q Query) MinScore(score float64) Query {
+	q.filter.MinScore = score
+	return q
+}
+
+// Tags matches the proxies having every one of tags
+func (q Query) Tags(tags ...string) Query {
+	q.filter.Tags = append(append([]string(nil), q.filter.Tags...), tags...)
+	return q
+}
+
+// Targets matches the proxies usable for every one of targets
+func (q Query) Targets(targets ...string) Query {
+	q.filter.Targets = append(append([]string(nil), q.filter.Targets...), targets...)
+	return q
+}
+
+// SortBy sorts the matches by key, proxies equal by a key are sorted by the keys given after it
+func (q Query) SortBy(keys ...SortKey) Query {
+	q.filter.Sort = append(append([]SortKey(nil), q.filter.Sort...), keys...)
+	return q
+}
+
+// Limit keeps the first n matches once sorted, zero keeps every match
+func (q Query) Limit(n int) Query {
+	q.filter.Limit = n
+	return q
+}
+
+// Filter returns the filter built by the query, it can be given to any Store
+func (q Query) Filter() Filter {
+	return q.filter.clone()
+}
+
+// Apply returns the proxies of list matching the query in the query's order
+func (q Query) Apply(proxies []*Proxy) []*Proxy {
+	return q.filter.Apply(proxies)
+}
+
+// Find returns the proxies of store matching the query
+func (q Query) Find(ctx context.Context, store Store) ([]*Proxy, error) {
+	return store.Find(ctx, q.Filter())
+}
+
+// GroupBy returns the proxies of list matching the query grouped by the value key returns for each of them, groups keep
+// the query's order and Limit applies to each group
+func (q Query) GroupBy(proxies []*Proxy, key func(*Proxy) string) map[string][]*Proxy {
+	limit := q.filter.Limit
+	q.filter.Limit = 0
+	groups := GroupBy(q.Apply(proxies), key)
+	if limit > 0 {
+		for name, group := range groups {
+			if len(group) > limit {
+				groups[name] = group[:limit]
+			}
+		}
+	}
+	return groups
+}
+
+// GroupBy returns proxies grouped by the value key returns for each of them, the order of proxies is kept in each group
+// ByCountry, ByASN, ByProtocol and ByAnonymity are common keys
+func GroupBy(proxies []*Proxy, key func(*Proxy) string) map[string][]*Proxy {
+	groups := make(map[string][]*Proxy)
+	for _, proxy := range proxies {
+		k := key(proxy)
+		groups[k] = append(groups[k], proxy)
+	}
+	return groups
+}
+
+// ByCountry groups proxies by country code
+func ByCountry(proxy *Proxy) string {
+	return proxy.Country()
+}
+
+// ByASN groups proxies by the autonomous system announcing their address, proxies with an unknown ASN are grouped
+// under 0
+func ByASN(proxy *Proxy) string {
+	return strconv.FormatUint(uint64(proxy.Geo().ASN), 10)
+}
+
+// ByProtocol groups proxies by protocol
+func ByProtocol(proxy *Proxy) string {
+	return string(proxy.Protocol())
+}
+
+// ByAnonymity groups proxies by anonymity level
+func ByAnonymity(proxy *Proxy) string {
+	return proxy.Anonymity().String()
+}
+
+// sortProxies sorts proxies by keys, the score and declared rank of each proxy are computed once
+func sortProxies(proxies []*Proxy, keys []SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+	scores := make(map[*Proxy]float64)
+	ranks := make(map[*Proxy]int)
+	for _, key := range keys {
+		switch key {
+		case SortScore:
+			now := time.Now()
+			for _, proxy := range proxies {
+				scores[proxy] = proxy.History().Reliability(now, DefaultHalfLife).Score
+			}
+		case SortDeclared:
+			for _, proxy := range proxies {
+				ranks[proxy] = declaredRank(proxy)
+			}
+		}
+	}
+	sort.SliceStable(proxies, func(i, j int) bool {
+		a, b := proxies[i], proxies[j]
+		for _, key := range keys {
+			switch key {
+			case SortLatency:
+				x, y := a.ResponseTime(), b.ResponseTime()
+				if x != y {
+					return y == 0 || (x != 0 && x < y)
+				}
+			case SortScore:
+				if scores[a] != scores[b] {
+					return scores[a] > scores[b]
+				}
+			case SortDeclared:
+				if ranks[a] != ranks[b] {
+					return ranks[a] > ranks[b]
+				}
+			case SortLastChecked:
+				x, y := a.LastChecked(), b.LastChecked()
+				if !x.Equal(y) {
+					return x.After(y)
+				}
+			}
+		}
+		return false
+	})
+}
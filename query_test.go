@@ -0,0 +1,71 @@
+package groxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuery_Apply(t *testing.T) {
+	now := time.Now()
+	fast := FromRecord(Record{Protocol: SOCKS5, Host: "1.1.1.1:1080", Anonymity: Elite, Alive: true, Country: "US",
+		ResponseTime: 100 * time.Millisecond, LastChecked: now.Add(-time.Hour), Tags: []string{"free", "scraped"}})
+	slow := FromRecord(Record{Protocol: HTTP, Host: "2.2.2.2:8080", Anonymity: Transparent, Alive: true, Country: "DE",
+		ResponseTime: 2 * time.Second, LastChecked: now, Tags: []string{"paid"}})
+	dead := FromRecord(Record{Protocol: HTTP, Host: "3.3.3.3:8080", Country: "US", LastChecked: now.Add(-time.Minute)})
+	untimed := FromRecord(Record{Protocol: SOCKS4, Host: "4.4.4.4:1080"})
+	for i := 0; i < 10; i++ {
+		slow.History().Add(CheckRecord{Time: now, OK: true})
+	}
+	fast.History().Add(CheckRecord{Time: now, OK: true})
+	proxies := []*Proxy{untimed, dead, slow, fast}
+
+	base := NewQuery().Alive()
+	tests := []struct {
+		name  string
+		query Query
+		want  []*Proxy
+	}{
+		{"everything", NewQuery(), proxies},
+		{"alive", base, []*Proxy{slow, fast}},
+		{"dead", NewQuery().Dead(), []*Proxy{untimed, dead}},
+		{"anonymous", base.MinAnonymity(Anonymous), []*Proxy{fast}},
+		{"protocols", NewQuery().Protocols(SOCKS4, SOCKS5), []*Proxy{untimed, fast}},
+		{"latency", base.MaxLatency(time.Second), []*Proxy{fast}},
+		{"country", NewQuery().Countries("us"), []*Proxy{dead, fast}},
+		{"tags", NewQuery().Tags("free").Tags("scraped"), []*Proxy{fast}},
+		{"score", NewQuery().MinScore(0.3), []*Proxy{slow}},
+		{"sort by latency", NewQuery().SortBy(SortLatency), []*Proxy{fast, slow, untimed, dead}},
+		{"sort by score", base.SortBy(SortScore), []*Proxy{slow, fast}},
+		{"sort by last checked", NewQuery().SortBy(SortLastChecked), []*Proxy{slow, dead, fast, untimed}},
+		{"top n", NewQuery().SortBy(SortLatency).Limit(1), []*Proxy{fast}},
+		{"filter", QueryFrom(Filter{Countries: []string{"DE"}}), []*Proxy{slow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Apply(proxies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", hosts(got), hosts(tt.want))
+			}
+		})
+	}
+}
+
+func TestQuery_GroupBy(t *testing.T) {
+	us := FromRecord(Record{Protocol: HTTP, Host: "1.1.1.1:80", Country: "US", Alive: true, ResponseTime: time.Second})
+	usFaster := FromRecord(Record{Protocol: HTTP, Host: "2.2.2.2:80", Country: "US", Alive: true,
+		ResponseTime: time.Millisecond})
+	de := FromRecord(Record{Protocol: HTTP, Host: "3.3.3.3:80", Country: "DE", Alive: true, ResponseTime: time.Second})
+	got := NewQuery().SortBy(SortLatency).Limit(1).GroupBy([]*Proxy{us, usFaster, de}, ByCountry)
+	want := map[string][]*Proxy{"US": {usFaster}, "DE": {de}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupBy() = %v, want %v", got, want)
+	}
+}
+
+func hosts(proxies []*Proxy) []string {
+	var list []string
+	for _, proxy := range proxies {
+		list = append(list, proxy.Host())
+	}
+	return list
+}
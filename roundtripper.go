@@ -0,0 +1,155 @@
+package groxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RoundTripper is an http.RoundTripper which sends each request through a proxy chosen from a Pool
+// Requests which fail to connect are retried through a different proxy, and the failing proxy is reported to the pool so
+// proxies that keep failing are evicted
+type RoundTripper struct {
+	pool       *Pool
+	retries    int
+	mu         sync.Mutex
+	transports map[*Proxy]*http.Transport
+	swept      uint64
+	observer   Observer
+}
+
+// NewRoundTripper constructs a RoundTripper which rotates through the proxies in pool, failed requests are retried twice
+func NewRoundTripper(pool *Pool) *RoundTripper {
+	return &RoundTripper{pool: pool, retries: 2, transports: make(map[*Proxy]*http.Transport)}
+}
+
+// NewClient returns an http.Client which sends every request through the proxies in pool
+func NewClient(pool *Pool, timeout time.Duration) *http.Client {
+	return &http.Client{Transport: NewRoundTripper(pool), Timeout: timeout}
+}
+
+// SetRetries sets how many times a request is retried through another proxy after a connection failure
+func (rt *RoundTripper) SetRetries(retries int) {
+	rt.retries = retries
+}
+
+// Pool returns the pool the RoundTripper chooses proxies from
+func (rt *RoundTripper) Pool() *Pool {
+	return rt.pool
+}
+
+// RoundTrip implements http.RoundTripper, requests with a body are only retried when the body can be replayed using
+// req.GetBody
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[*Proxy]bool)
+	var lastErr error
+	for attempt := 0; attempt <= rt.retries; attempt++ {
+		proxy, err := rt.pool.NextExcluding(tried)
+		if err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		tried[proxy] = true
+
+		outReq := req
+		if attempt > 0 {
+			if outReq, err = rewindRequest(req); err != nil {
+				return nil, lastErr
+			}
+		}
+		start := time.Now()
+		resp, err := rt.transport(proxy).RoundTrip(outReq)
+		if rt.observer != nil {
+			rt.observer.ProxyUsed(proxy, time.Since(start), err)
+		}
+		if err == nil {
+			rt.pool.Success(proxy)
+			return resp, nil
+		}
+		lastErr = err
+		// a canceled request says nothing about the proxy
+		if req.Context().Err() != nil {
+			break
+		}
+		if rt.pool.Failure(proxy) {
+			rt.evict(proxy)
+		}
+		if !replayable(req) {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// replayable returns whether req can be sent again, requests without a body or whose body can be recreated with GetBody
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of req with a fresh body so it can be sent again
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	outReq := req.Clone(req.Context())
+	outReq.Body = body
+	return outReq, nil
+}
+
+// transport returns the cached transport used to send requests through proxy, connections are kept alive between requests
+// The transports of proxies which left the pool since the last call are dropped first
+func (rt *RoundTripper) transport(proxy *Proxy) *http.Transport {
+	removals := rt.pool.removalCount()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if removals != rt.swept {
+		rt.sweep()
+		rt.swept = removals
+	}
+	transport, ok := rt.transports[proxy]
+	if !ok {
+		transport = newTransport(proxy)
+		transport.DisableKeepAlives = false
+		rt.transports[proxy] = transport
+	}
+	return transport
+}
+
+// sweep closes and forgets the transports of the proxies no longer in the pool, rt.mu must be held
+func (rt *RoundTripper) sweep() {
+	current := make(map[*Proxy]bool)
+	for _, proxy := range rt.pool.Proxies() {
+		current[proxy] = true
+	}
+	for proxy, transport := range rt.transports {
+		if !current[proxy] {
+			transport.CloseIdleConnections()
+			delete(rt.transports, proxy)
+		}
+	}
+}
+
+// evict closes and forgets the transport of a proxy which was removed from the pool
+func (rt *RoundTripper) evict(proxy *Proxy) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if transport, ok := rt.transports[proxy]; ok {
+		transport.CloseIdleConnections()
+		delete(rt.transports, proxy)
+	}
+}
+
+// CloseIdleConnections closes the idle connections of every proxy transport
+func (rt *RoundTripper) CloseIdleConnections() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, transport := range rt.transports {
+		transport.CloseIdleConnections()
+	}
+}
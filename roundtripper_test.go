@@ -0,0 +1,128 @@
+package groxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTripper_RoundTrip(t *testing.T) {
+	// an http proxy receives the absolute url of plain http requests, so a handler can stand in for one
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("via proxy"))
+	}))
+	defer upstream.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := l.Addr().String()
+	l.Close()
+
+	alive := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	dead := New(deadAddr, "", "")
+	tests := []struct {
+		name      string
+		proxies   []*Proxy
+		retries   int
+		wantErr   bool
+		wantPool  int
+		wantProxy *Proxy
+	}{
+		{"retries through another proxy", []*Proxy{dead, alive}, 1, false, 1, alive},
+		{"no retries left", []*Proxy{dead, alive}, 0, true, 1, alive},
+		{"empty pool", nil, 1, true, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(RoundRobin(), tt.proxies...)
+			pool.SetMaxFailures(1)
+			rt := NewRoundTripper(pool)
+			rt.SetRetries(tt.retries)
+			client := &http.Client{Transport: rt, Timeout: time.Second}
+
+			resp, err := client.Get("http://example.com/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != "via proxy" {
+					t.Errorf("RoundTrip() body = %q, want %q", body, "via proxy")
+				}
+			}
+			if pool.Len() != tt.wantPool {
+				t.Errorf("Pool.Len() = %v, want %v", pool.Len(), tt.wantPool)
+			}
+			if tt.wantProxy != nil && pool.Proxies()[0] != tt.wantProxy {
+				t.Errorf("Pool.Proxies() = %v, want %v", pool.Proxies(), tt.wantProxy)
+			}
+		})
+	}
+}
+
+func TestRoundTripper_sweepsRemovedProxies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("via proxy"))
+	}))
+	defer upstream.Close()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	// distinct proxies for the same upstream each get their own transport
+	first, second, third := New(host, "", ""), New(host, "", ""), New(host, "", "")
+	pool := NewPool(RoundRobin(), first, second, third)
+	rt := NewRoundTripper(pool)
+	client := &http.Client{Transport: rt, Timeout: time.Second}
+	get := func() {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	for i := 0; i < 3; i++ {
+		get()
+	}
+
+	pool.Remove(first)
+	pool.Prune(1.1, 0)
+	pool.Add(third)
+	get()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.transports[third]; len(rt.transports) != 1 || !ok {
+		t.Errorf("transports = %v, want only the one of the proxy left in the pool", rt.transports)
+	}
+}
+
+func TestRoundTripper_canceledRequest(t *testing.T) {
+	handling := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(handling)
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	pool := NewPool(RoundRobin(), proxy)
+	pool.SetMaxFailures(1)
+	client := &http.Client{Transport: NewRoundTripper(pool)}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-handling
+		cancel()
+	}()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("RoundTrip() error = nil, want the canceled request's error")
+	}
+	if pool.Len() != 1 || len(pool.Failing()) != 0 {
+		t.Errorf("Pool.Proxies() = %v, failing %v, want the proxy kept without failures", pool.Proxies(), pool.Failing())
+	}
+}
@@ -0,0 +1,334 @@
+package groxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sighting is when a source listed a proxy, FirstSeen is the first harvest which found it and LastSeen the latest
+type Sighting struct {
+	Source    string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Sightings returns when each source listed the proxy, sorted by source
+func (h *Proxy) Sightings() []Sighting {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sightingList()
+}
+
+// sightingList returns the sightings sorted by source, h.mu must be held
+func (h *Proxy) sightingList() []Sighting {
+	var sightings []Sighting
+	for _, sighting := range h.sightings {
+		sightings = append(sightings, sighting)
+	}
+	sort.Slice(sightings, func(i, j int) bool { return sightings[i].Source < sightings[j].Source })
+	return sightings
+}
+
+// FirstSeen returns when a source first listed the proxy, it is zero for proxies no source listed
+func (h *Proxy) FirstSeen() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var first time.Time
+	for _, sighting := range h.sightings {
+		if first.IsZero() || sighting.FirstSeen.Before(first) {
+			first = sighting.FirstSeen
+		}
+	}
+	return first
+}
+
+// LastSeen returns when a source last listed the proxy, it is zero for proxies no source listed
+func (h *Proxy) LastSeen() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var last time.Time
+	for _, sighting := range h.sightings {
+		if sighting.LastSeen.After(last) {
+			last = sighting.LastSeen
+		}
+	}
+	return last
+}
+
+// addSighting records that source listed the proxy between first and last
+func (h *Proxy) addSighting(sighting Sighting) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sightings == nil {
+		h.sightings = make(map[string]Sighting)
+	}
+	if known, ok := h.sightings[sighting.Source]; ok {
+		if known.FirstSeen.Before(sighting.FirstSeen) {
+			sighting.FirstSeen = known.FirstSeen
+		}
+		if known.LastSeen.After(sighting.LastSeen) {
+			sighting.LastSeen = known.LastSeen
+		}
+	}
+	h.sightings[sighting.Source] = sighting
+}
+
+// SourceStatus is the freshness of a source scheduled with Scheduler.Schedule
+type SourceStatus struct {
+	// Name is the Source of the provider's last response, or the name of its function before it first ran
+	Name     string
+	Interval time.Duration
+	LastRun  time.Time
+	NextRun  time.Time
+	// LastSuccess is when the source last returned proxies without an error
+	LastSuccess time.Time
+	// Report is the outcome of the last run
+	Report ProviderReport
+}
+
+// scheduledSource is a provider run by a Scheduler
+type scheduledSource struct {
+	provider Provider
+	status   SourceStatus
+	running  bool
+}
+
+// Scheduler re-harvests each of its providers on its own interval and keeps the proxies they list. Proxies seen for the
+// first time are handed to a Manager for checking, proxies no source listed for a while are retired
+type Scheduler struct {
+	mu          sync.Mutex
+	manager     *Manager
+	monitor     *Monitor
+	sources     []*scheduledSource
+	proxies     map[string]*Proxy
+	retireAfter time.Duration
+	timeout     time.Duration
+	wake        chan struct{}
+}
+
+// NewScheduler constructs a scheduler checking new proxies with manager, proxies are retired once no source listed them
+// for a day
+func NewScheduler(manager *Manager) *Scheduler {
+	return &Scheduler{
+		manager:     manager,
+		proxies:     make(map[string]*Proxy),
+		retireAfter: 24 * time.Hour,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Schedule harvests provider every interval, the first harvest happens as soon as the scheduler runs. It returns an
+// error when interval is not positive, the provider would be harvested again and again otherwise
+func (s *Scheduler) Schedule(provider Provider, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("groxy: interval of provider %s must be positive, got %v", providerName(provider), interval)
+	}
+	s.mu.Lock()
+	s.sources = append(s.sources, &scheduledSource{
+		provider: provider,
+		status:   SourceStatus{Name: providerName(provider), Interval: interval},
+	})
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetRetireAfter sets how long a proxy is kept after the last time a source listed it, zero never retires proxies
+func (s *Scheduler) SetRetireAfter(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retireAfter = d
+}
+
+// SetTimeout sets the deadline given to each provider, a zero duration means no deadline
+func (s *Scheduler) SetTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeout = timeout
+}
+
+// SetMonitor makes the scheduler hand the proxies which pass their first check to monitor, and remove the proxies it
+// retires from it
+func (s *Scheduler) SetMonitor(monitor *Monitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.monitor = monitor
+}
+
+// Proxies returns the proxies listed by a source and not retired yet
+func (s *Scheduler) Proxies() []*Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proxies := make([]*Proxy, 0, len(s.proxies))
+	for _, proxy := range s.proxies {
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// Sources returns the freshness of each scheduled source, in the order they were scheduled
+func (s *Scheduler) Sources() []SourceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]SourceStatus, 0, len(s.sources))
+	for _, source := range s.sources {
+		statuses = append(statuses, source.status)
+	}
+	return statuses
+}
+
+// Run harvests the sources whenever they are due until ctx is done and returns the results of checking the new
+// proxies. The channel must be drained, it is closed once ctx is done and the harvests and checks in flight returned
+func (s *Scheduler) Run(ctx context.Context) <-chan TestResult {
+	discovered := make(chan *Proxy)
+	results := s.manager.RunStream(ctx, discovered)
+	out := make(chan TestResult)
+	go func() {
+		defer close(out)
+		for result := range results {
+			s.mu.Lock()
+			monitor := s.monitor
+			s.mu.Unlock()
+			if monitor != nil && result.Err == nil {
+				monitor.Add(result.Proxy)
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	go func() {
+		var wg sync.WaitGroup
+		defer close(discovered)
+		defer wg.Wait()
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			due, wait := s.due(time.Now())
+			for _, source := range due {
+				wg.Add(1)
+				go func(source *scheduledSource) {
+					defer wg.Done()
+					s.harvest(ctx, source, discovered)
+				}(source)
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// due marks the sources due at now as running and returns them with the time until the next one is due
+func (s *Scheduler) due(now time.Time) ([]*scheduledSource, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*scheduledSource
+	wait := time.Hour
+	for _, source := range s.sources {
+		if source.running {
+			continue
+		}
+		if !source.status.NextRun.After(now) {
+			source.running = true
+			due = append(due, source)
+			continue
+		}
+		if until := source.status.NextRun.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	return due, wait
+}
+
+// harvest runs source, records the proxies it listed, sends the new ones on discovered and retires the proxies no
+// source listed recently
+func (s *Scheduler) harvest(ctx context.Context, source *scheduledSource, discovered chan<- *Proxy) {
+	s.mu.Lock()
+	harvester := &Harvester{timeout: s.timeout, observer: s.manager.observer}
+	s.mu.Unlock()
+	start := time.Now()
+	resp := harvester.runProvider(ctx, source.provider)
+	now := time.Now()
+
+	s.mu.Lock()
+	source.running = false
+	if ctx.Err() != nil {
+		// the scheduler stopped, the harvest was given up on rather than run
+		s.mu.Unlock()
+		return
+	}
+	source.status.Name = resp.Source
+	source.status.LastRun = start
+	source.status.NextRun = start.Add(source.status.Interval)
+	source.status.Report = ProviderReport{Source: resp.Source, Proxies: len(resp.Proxies), Duration: now.Sub(start),
+		Err: resp.Err, Rejected: resp.Rejected}
+	if resp.Err == nil && len(resp.Proxies) > 0 {
+		source.status.LastSuccess = start
+	}
+	var fresh []*Proxy
+	for _, proxy := range resp.Proxies {
+		known, ok := s.proxies[proxy.Host()]
+		if !ok {
+			known = proxy
+			s.proxies[proxy.Host()] = proxy
+			fresh = append(fresh, proxy)
+		}
+		mergeDuplicate(known, proxy)
+		known.addSighting(Sighting{Source: resp.Source, FirstSeen: start, LastSeen: start})
+	}
+	retired := s.retire(now)
+	monitor := s.monitor
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	if monitor != nil {
+		for _, proxy := range retired {
+			monitor.Remove(proxy)
+		}
+	}
+	for _, proxy := range fresh {
+		select {
+		case discovered <- proxy:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// retire removes the proxies no source listed since retireAfter before now and returns them, s.mu must be held
+func (s *Scheduler) retire(now time.Time) []*Proxy {
+	if s.retireAfter <= 0 {
+		return nil
+	}
+	var retired []*Proxy
+	for host, proxy := range s.proxies {
+		if now.Sub(proxy.LastSeen()) > s.retireAfter {
+			delete(s.proxies, host)
+			retired = append(retired, proxy)
+		}
+	}
+	return retired
+}
@@ -0,0 +1,97 @@
+package groxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	good := strings.TrimPrefix(upstream.URL, "http://")
+	bad := strings.TrimPrefix(closed.URL, "http://")
+
+	var runs int32
+	steady := func(ctx context.Context) ProviderResponse {
+		atomic.AddInt32(&runs, 1)
+		return ProviderResponse{Source: "steady", Proxies: []*Proxy{New(good, "", "")}}
+	}
+	var onceRuns int32
+	once := func(ctx context.Context) ProviderResponse {
+		if atomic.AddInt32(&onceRuns, 1) > 1 {
+			return ProviderResponse{Source: "once"}
+		}
+		return ProviderResponse{Source: "once", Proxies: []*Proxy{New(bad, "", ""), New(good, "", "")}}
+	}
+	manager := NewManager(2, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	monitor := NewMonitor(manager)
+	scheduler := NewScheduler(manager)
+	for _, provider := range []Provider{steady, once} {
+		if err := scheduler.Schedule(provider, 10*time.Millisecond); err != nil {
+			t.Fatalf("Schedule() error = %v", err)
+		}
+	}
+	if err := scheduler.Schedule(steady, 0); err == nil {
+		t.Error("Schedule() with a zero interval succeeded, want an error")
+	}
+	scheduler.SetRetireAfter(50 * time.Millisecond)
+	scheduler.SetMonitor(monitor)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results := scheduler.Run(ctx)
+	checked := make(map[string]bool)
+	for len(checked) < 2 {
+		result, ok := <-results
+		if !ok {
+			t.Fatalf("Run() closed after checking %v", checked)
+		}
+		if checked[result.Proxy.Host()] {
+			t.Errorf("Run() checked %s twice", result.Proxy.Host())
+		}
+		checked[result.Proxy.Host()] = result.Err == nil
+	}
+	if !checked[good] || checked[bad] {
+		t.Errorf("Run() results = %v, want %s alive and %s dead", checked, good, bad)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if proxies := scheduler.Proxies(); len(proxies) == 1 && proxies[0].Host() == good {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	for range results {
+	}
+
+	proxies := scheduler.Proxies()
+	if len(proxies) != 1 || proxies[0].Host() != good {
+		t.Fatalf("Proxies() = %v, want only %s once the other was retired", hosts(proxies), good)
+	}
+	sightings := proxies[0].Sightings()
+	firstSeen := proxies[0].FirstSeen()
+	if len(sightings) != 2 || sightings[0].Source != "once" || sightings[1].Source != "steady" ||
+		!sightings[1].LastSeen.After(sightings[0].LastSeen) || firstSeen.After(sightings[0].FirstSeen) ||
+		firstSeen.After(sightings[1].FirstSeen) {
+		t.Errorf("Sightings() = %+v, want once then steady seen later", sightings)
+	}
+	if monitored := monitor.Proxies(); len(monitored) != 1 || monitored[0].Host() != good {
+		t.Errorf("monitor Proxies() = %v, want %s", hosts(monitored), good)
+	}
+	sources := scheduler.Sources()
+	steadyRuns := atomic.LoadInt32(&runs)
+	if len(sources) != 2 || sources[0].Name != "steady" || sources[0].LastSuccess.IsZero() ||
+		!sources[1].LastSuccess.Before(sources[1].LastRun) || steadyRuns < 2 {
+		t.Errorf("Sources() = %+v after %d runs, want steady fresh and once stale", sources, steadyRuns)
+	}
+}
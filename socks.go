@@ -0,0 +1,166 @@
+package groxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	netproxy "golang.org/x/net/proxy"
+)
+
+// contextDialer is implemented by every dialer used to connect through a proxy
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// newTransport returns an http.Transport which sends every request through proxy
+// SOCKS proxies are dialled with a socks dialer, HTTP proxies use the proxy support built into net/http
+func newTransport(proxy *Proxy) *http.Transport {
+	transport := &http.Transport{DisableKeepAlives: true}
+	if proxy.Protocol().IsSOCKS() {
+		transport.DialContext = proxy.socksDialer(&net.Dialer{}).DialContext
+		return transport
+	}
+	transport.Proxy = http.ProxyURL(proxy.httpURL())
+	return transport
+}
+
+// httpURL returns the url used by net/http to reach an http proxy, proxies listed as https still accept plain http and
+// tunnel https traffic with CONNECT
+func (h *Proxy) httpURL() *url.URL {
+	u := *h.url
+	u.Scheme = string(HTTP)
+	return &u
+}
+
+// socksDialer returns a dialer which connects through the proxy using its socks version, forward is used to reach the proxy
+func (h *Proxy) socksDialer(forward *net.Dialer) contextDialer {
+	switch h.Protocol() {
+	case SOCKS4, SOCKS4A:
+		return &socks4Dialer{
+			proxyAddr: h.Host(),
+			userID:    h.Username(),
+			remoteDNS: h.Protocol() == SOCKS4A,
+			forward:   forward,
+		}
+	}
+	var auth *netproxy.Auth
+	if h.Username() != "" {
+		auth = &netproxy.Auth{User: h.Username(), Password: h.Password()}
+	}
+	dialer, err := netproxy.SOCKS5("tcp", h.Host(), auth, forward)
+	if err != nil {
+		return errDialer{err: err}
+	}
+	return dialer.(contextDialer)
+}
+
+// errDialer is a dialer which always fails with err
+type errDialer struct {
+	err error
+}
+
+func (d errDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil, d.err
+}
+
+// socks4 reply codes, see https://www.openssh.com/txt/socks4.protocol
+const (
+	socks4Version  = 4
+	socks4Connect  = 1
+	socks4Granted  = 90
+	socks4Rejected = 91
+)
+
+// socks4Dialer dials through a socks4 or socks4a proxy, the protocol has no authentication other than a user id
+type socks4Dialer struct {
+	proxyAddr string
+	userID    string
+	remoteDNS bool
+	forward   *net.Dialer
+}
+
+// DialContext connects to addr through the proxy, only tcp networks are supported
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks4: network %s is not supported", network)
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: invalid port %s", portStr)
+	}
+
+	req := []byte{socks4Version, socks4Connect, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], uint16(port))
+	ip := net.ParseIP(host).To4()
+	if ip == nil && !d.remoteDNS {
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("socks4: no ipv4 address for %s", host)
+		}
+		ip = addrs[0].To4()
+	}
+	if ip == nil {
+		// socks4a signals a hostname by sending the invalid address 0.0.0.x
+		req = append(req, 0, 0, 0, 1)
+	} else {
+		req = append(req, ip...)
+	}
+	req = append(req, d.userID...)
+	req = append(req, 0)
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, handshakeErr(ctx, err)
+	}
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		conn.Close()
+		return nil, handshakeErr(ctx, err)
+	}
+	if reply[0] != 0 {
+		conn.Close()
+		return nil, errors.New("socks4: invalid reply from proxy")
+	}
+	if reply[1] != socks4Granted {
+		conn.Close()
+		if reply[1] == socks4Rejected {
+			return nil, errors.New("socks4: request rejected by proxy")
+		}
+		return nil, fmt.Errorf("socks4: request failed with code %d", reply[1])
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	return conn, nil
+}
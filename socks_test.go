@@ -0,0 +1,93 @@
+package groxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+// serveSOCKS4 accepts a single connection on l, records the request and replies with code
+func serveSOCKS4(t *testing.T, l net.Listener, code byte, requests chan<- []byte) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil && err != io.EOF {
+		t.Errorf("reading socks4 request: %v", err)
+		return
+	}
+	requests <- buf[:n]
+	conn.Write([]byte{0, code, 0, 0, 0, 0, 0, 0})
+}
+
+func Test_socks4Dialer_DialContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		addr      string
+		userID    string
+		remoteDNS bool
+		code      byte
+		want      []byte
+		wantErr   bool
+	}{
+		{"ip address", "1.2.3.4:80", "", false, socks4Granted, []byte{4, 1, 0, 80, 1, 2, 3, 4, 0}, false},
+		{"user id", "1.2.3.4:443", "bob", false, socks4Granted, []byte{4, 1, 1, 187, 1, 2, 3, 4, 'b', 'o', 'b', 0}, false},
+		{"socks4a hostname", "example.com:80", "", true, socks4Granted,
+			append([]byte{4, 1, 0, 80, 0, 0, 0, 1, 0}, append([]byte("example.com"), 0)...), false},
+		{"rejected", "1.2.3.4:80", "", false, socks4Rejected, []byte{4, 1, 0, 80, 1, 2, 3, 4, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			requests := make(chan []byte, 1)
+			go serveSOCKS4(t, l, tt.code, requests)
+
+			d := &socks4Dialer{proxyAddr: l.Addr().String(), userID: tt.userID, remoteDNS: tt.remoteDNS, forward: &net.Dialer{}}
+			conn, err := d.DialContext(context.Background(), "tcp", tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("socks4Dialer.DialContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+			if got := <-requests; !bytes.Equal(got, tt.want) {
+				t.Errorf("socks4Dialer.DialContext() sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWithProtocol(t *testing.T) {
+	tests := []struct {
+		name         string
+		protocol     Protocol
+		username     string
+		password     string
+		wantUsername string
+		wantPassword string
+	}{
+		{"socks5 with auth", SOCKS5, "user", "pass", "user", "pass"},
+		{"socks4 user id only", SOCKS4, "user", "", "user", ""},
+		{"http without auth", HTTP, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewWithProtocol(tt.protocol, "1.2.3.4:1080", tt.username, tt.password)
+			if got.Protocol() != tt.protocol {
+				t.Errorf("Protocol() = %v, want %v", got.Protocol(), tt.protocol)
+			}
+			if got.Username() != tt.wantUsername || got.Password() != tt.wantPassword {
+				t.Errorf("credentials = %v:%v, want %v:%v", got.Username(), got.Password(), tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package groxy
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the lists fetched by a SourceConfig
+const (
+	// FormatLines lists one proxy per line, only the first whitespace separated field of each line is read
+	FormatLines = "lines"
+	// FormatJSONLines lists one JSON object per line, Fields names the keys holding each part of the proxy
+	FormatJSONLines = "jsonl"
+	// FormatCSV lists one proxy per row, Fields holds the zero based column of each part of the proxy
+	FormatCSV = "csv"
+	// FormatRegex finds proxies anywhere in the body with Pattern, Fields names the groups holding each part of the proxy
+	FormatRegex = "regex"
+)
+
+// SourceConfig describes a proxy list published on the web, Provider turns it into a Provider so new sources can be
+// added without writing code. Configs are usually loaded from a file with LoadSources
+type SourceConfig struct {
+	// Name is the Source of the responses returned by the provider
+	Name string `json:"name" yaml:"name"`
+	// URLs are the lists fetched, a {protocol} placeholder fetches the url once for each of Protocols
+	URLs []string `json:"urls" yaml:"urls"`
+	// Protocols are substituted in the urls, the proxies of each list are tagged with the protocol it was fetched for
+	Protocols []Protocol `json:"protocols,omitempty" yaml:"protocols,omitempty"`
+	// Protocol tags the proxies of urls without a placeholder, it defaults to HTTP
+	Protocol Protocol `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	// Format is one of FormatLines, FormatJSONLines, FormatCSV, FormatRegex or FormatHTMLTable, it defaults to FormatLines
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// SkipLines and SkipTrailing are the number of header and footer lines ignored in each list
+	SkipLines    int `json:"skip_lines,omitempty" yaml:"skip_lines,omitempty"`
+	SkipTrailing int `json:"skip_trailing,omitempty" yaml:"skip_trailing,omitempty"`
+	// Comma is the csv field delimiter, it defaults to a comma
+	Comma string `json:"comma,omitempty" yaml:"comma,omitempty"`
+	// Pattern is the regular expression of FormatRegex, unless Fields names other groups the named groups host, port,
+	// protocol, username and password hold the parts of the proxy
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// Fields maps the parts of a proxy to where the format finds them
+	Fields SourceFields `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Table describes the tables and pages of FormatHTMLTable
+	Table TableOptions `json:"table,omitempty" yaml:"table,omitempty"`
+}
+
+// SourceFields locates the parts of a proxy in a list entry, empty parts are not read except Host which has a default
+// for each format. Host may hold the port as well, in host:port form or as a full proxy url
+type SourceFields struct {
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	Port     string `json:"port,omitempty" yaml:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Declared maps the attributes the list claims about its proxies, such as anonymity or country, to where the format
+	// finds them. They are recorded with Proxy.Declare
+	Declared map[string]string `json:"declared,omitempty" yaml:"declared,omitempty"`
+}
+
+// sourceFile is the document read by LoadSources
+type sourceFile struct {
+	Sources []SourceConfig `json:"sources" yaml:"sources"`
+}
+
+// LoadSources reads source configs from a YAML or JSON file with a top level sources list
+func LoadSources(file string) ([]SourceConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSources(data)
+}
+
+// ParseSources parses source configs from a YAML or JSON document with a top level sources list
+func ParseSources(data []byte) ([]SourceConfig, error) {
+	var doc sourceFile
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("groxy: parsing sources: %v", err)
+	}
+	return doc.Sources, nil
+}
+
+// ProvidersFromSources builds a provider from each config, every invalid config is reported in the returned error
+func ProvidersFromSources(configs []SourceConfig) ([]Provider, error) {
+	var result error
+	var providers []Provider
+	for _, config := range configs {
+		provider, err := config.Provider()
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers, result
+}
+
+// sourceParser parses a fetched list, proxies without a protocol of their own speak protocol
+type sourceParser func(body string, protocol Protocol) ([]*Proxy, []*ParseError)
+
+// Provider validates the config and returns a provider fetching every url concurrently
+func (c SourceConfig) Provider() (Provider, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("groxy: source has no name")
+	}
+	if len(c.URLs) == 0 {
+		return nil, fmt.Errorf("groxy: source %s has no urls", c.Name)
+	}
+	parse, err := c.parser()
+	if err != nil {
+		return nil, fmt.Errorf("groxy: source %s: %v", c.Name, err)
+	}
+	type list struct {
+		url      string
+		protocol Protocol
+	}
+	var lists []list
+	for _, u := range c.URLs {
+		if !strings.Contains(u, "{protocol}") {
+			protocol := c.Protocol
+			if protocol == "" {
+				protocol = HTTP
+			}
+			lists = append(lists, list{u, protocol})
+			continue
+		}
+		if len(c.Protocols) == 0 {
+			return nil, fmt.Errorf("groxy: source %s: url %s has a {protocol} placeholder but no protocols", c.Name, u)
+		}
+		for _, protocol := range c.Protocols {
+			lists = append(lists, list{strings.Replace(u, "{protocol}", string(protocol), -1), protocol})
+		}
+	}
+	for _, l := range lists {
+		if strings.Contains(l.url, "{page}") && c.Format != FormatHTMLTable {
+			return nil, fmt.Errorf("groxy: source %s: url %s has a {page} placeholder but is not an html table", c.Name,
+				l.url)
+		}
+	}
+	if c.Format == FormatHTMLTable {
+		numPages := c.Table.Pages
+		if numPages == 0 {
+			numPages = 1
+		}
+		if numPages < 1 {
+			return nil, fmt.Errorf("groxy: source %s: table pages must be at least 1, got %d", c.Name, c.Table.Pages)
+		}
+		var pages []list
+		for _, l := range lists {
+			if !strings.Contains(l.url, "{page}") {
+				pages = append(pages, l)
+				continue
+			}
+			for page := 1; page <= numPages; page++ {
+				pages = append(pages, list{strings.Replace(l.url, "{page}", strconv.Itoa(page), -1), l.protocol})
+			}
+		}
+		lists = pages
+	}
+
+	return func(ctx context.Context) ProviderResponse {
+		var resultErr error
+		var proxies []*Proxy
+		var rejected []*ParseError
+		respStream := make(chan ProviderResponse, len(lists))
+		wg := sync.WaitGroup{}
+		wg.Add(len(lists))
+		for _, l := range lists {
+			go func(l list) {
+				defer wg.Done()
+				found, bad, err := c.fetch(ctx, l.url, l.protocol, parse)
+				respStream <- ProviderResponse{Proxies: found, Err: err, Rejected: bad}
+			}(l)
+		}
+		wg.Wait()
+
+		close(respStream)
+		for result := range respStream {
+			proxies = append(proxies, result.Proxies...)
+			rejected = append(rejected, result.Rejected...)
+			if result.Err != nil {
+				resultErr = multierror.Append(resultErr, result.Err)
+			}
+		}
+		return ProviderResponse{Source: c.Name, Proxies: proxies, Err: resultErr, Rejected: rejected}
+	}, nil
+}
+
+// fetch fetches and parses a list, the pages linked by Table.NextLink are followed for FormatHTMLTable
+// The proxies of the pages read before an error are returned with it
+func (c SourceConfig) fetch(ctx context.Context, page string, protocol Protocol,
+	parse sourceParser) ([]*Proxy, []*ParseError, error) {
+	var proxies []*Proxy
+	var rejected []*ParseError
+	maxPages := c.Table.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	seen := map[string]bool{}
+	for n := 0; page != "" && n < maxPages && !seen[page]; n++ {
+		seen[page] = true
+		body, err := getBody(ctx, page)
+		if err != nil {
+			return proxies, rejected, err
+		}
+		found, bad := parse(body, protocol)
+		attribute(c.Name, found)
+		Emit(ctx, found...)
+		proxies = append(proxies, found...)
+		rejected = append(rejected, bad...)
+		if c.Format != FormatHTMLTable || c.Table.NextLink == "" {
+			break
+		}
+		page = c.Table.nextPage(body, page)
+	}
+	return proxies, rejected, nil
+}
+
+// parser returns the parser of the config format
+func (c SourceConfig) parser() (sourceParser, error) {
+	switch c.Format {
+	case "", FormatLines:
+		return func(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+			lines, offset := c.lines(body)
+			raw := append([]string(nil), lines...)
+			for i, line := range lines {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					lines[i] = fields[0]
+				}
+			}
+			proxies, rejected := parseLines(protocol, lines)
+			for _, parseErr := range rejected {
+				parseErr.Line += offset
+			}
+			for _, proxy := range proxies {
+				// the proxies are not shared yet, their only source is the line parseLines read them from
+				source := &proxy.sources[0]
+				source.Raw = strings.TrimSpace(raw[source.Line-1])
+				source.Line += offset
+			}
+			return proxies, rejected
+		}, nil
+	case FormatJSONLines:
+		fields := c.Fields
+		if fields.Host == "" {
+			fields.Host = "host"
+		}
+		return func(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+			var proxies []*Proxy
+			var rejected []*ParseError
+			lines, offset := c.lines(body)
+			for i, line := range lines {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				var v map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &v); err != nil {
+					rejected = append(rejected, &ParseError{Line: offset + i + 1, Input: line, Reason: "invalid json"})
+					continue
+				}
+				value := func(key string) string {
+					if key == "" || v[key] == nil {
+						return ""
+					}
+					return fmt.Sprint(v[key])
+				}
+				proxy, err := sourceProxy(protocol, value(fields.Host), value(fields.Port), value(fields.Protocol),
+					value(fields.Username), value(fields.Password))
+				if err != nil {
+					err.Line = offset + i + 1
+					rejected = append(rejected, err)
+					continue
+				}
+				fields.declare(proxy, value)
+				proxies = append(proxies, proxy.listed(offset+i+1, line))
+			}
+			return proxies, rejected
+		}, nil
+	case FormatCSV:
+		columns := map[string]int{}
+		parts := []struct{ name, column string }{
+			{"host", c.Fields.Host}, {"port", c.Fields.Port}, {"protocol", c.Fields.Protocol},
+			{"username", c.Fields.Username}, {"password", c.Fields.Password},
+		}
+		for _, part := range parts {
+			if part.column == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part.column)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s column %q is not a column number", part.name, part.column)
+			}
+			columns[part.name] = n
+		}
+		if _, ok := columns["host"]; !ok {
+			columns["host"] = 0
+		}
+		declared := SourceFields{Declared: map[string]string{}}
+		for name, column := range c.Fields.Declared {
+			n, err := strconv.Atoi(column)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s column %q is not a column number", name, column)
+			}
+			columns["declared "+name] = n
+			declared.Declared[name] = "declared " + name
+		}
+		comma := ','
+		if c.Comma != "" {
+			comma = []rune(c.Comma)[0]
+		}
+		return func(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+			var proxies []*Proxy
+			var rejected []*ParseError
+			lines, offset := c.lines(body)
+			reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+			reader.Comma = comma
+			reader.FieldsPerRecord = -1
+			reader.LazyQuotes = true
+			for {
+				row, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				line, _ := reader.FieldPos(0)
+				if err != nil {
+					rejected = append(rejected, &ParseError{Line: offset + line, Reason: err.Error()})
+					continue
+				}
+				value := func(part string) string {
+					n, ok := columns[part]
+					if !ok || n >= len(row) {
+						return ""
+					}
+					return strings.TrimSpace(row[n])
+				}
+				if value("host") == "" {
+					continue
+				}
+				proxy, parseErr := sourceProxy(protocol, value("host"), value("port"), value("protocol"),
+					value("username"), value("password"))
+				if parseErr != nil {
+					parseErr.Line = offset + line
+					rejected = append(rejected, parseErr)
+					continue
+				}
+				declared.declare(proxy, value)
+				if line > 0 && line <= len(lines) {
+					proxy.listed(offset+line, lines[line-1])
+				}
+				proxies = append(proxies, proxy)
+			}
+			return proxies, rejected
+		}, nil
+	case FormatRegex:
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		fields := c.Fields
+		for _, field := range []struct {
+			group *string
+			name  string
+		}{
+			{&fields.Host, "host"}, {&fields.Port, "port"}, {&fields.Protocol, "protocol"},
+			{&fields.Username, "username"}, {&fields.Password, "password"},
+		} {
+			if *field.group == "" {
+				*field.group = field.name
+			}
+		}
+		if pattern.SubexpIndex(fields.Host) < 0 {
+			return nil, fmt.Errorf("pattern has no %s group", fields.Host)
+		}
+		return func(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+			var proxies []*Proxy
+			var rejected []*ParseError
+			lines, _ := c.lines(body)
+			for _, match := range pattern.FindAllStringSubmatch(strings.Join(lines, "\n"), -1) {
+				value := func(group string) string {
+					if i := pattern.SubexpIndex(group); group != "" && i >= 0 {
+						return strings.TrimSpace(match[i])
+					}
+					return ""
+				}
+				proxy, err := sourceProxy(protocol, value(fields.Host), value(fields.Port), value(fields.Protocol),
+					value(fields.Username), value(fields.Password))
+				if err != nil {
+					err.Input = match[0]
+					rejected = append(rejected, err)
+					continue
+				}
+				fields.declare(proxy, value)
+				proxies = append(proxies, proxy.listed(0, match[0]))
+			}
+			return proxies, rejected
+		}, nil
+	case FormatHTMLTable:
+		if err := c.Table.validate(); err != nil {
+			return nil, err
+		}
+		return c.Table.parseTables, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", c.Format)
+}
+
+// declare records the attributes the list claims for proxy, value returns the text found where Declared locates each
+// attribute
+func (f SourceFields) declare(proxy *Proxy, value func(string) string) {
+	for name, where := range f.Declared {
+		proxy.Declare(name, value(where))
+	}
+}
+
+// lines splits body into lines without the header and footer lines, it also returns the number of header lines removed
+func (c SourceConfig) lines(body string) ([]string, int) {
+	lines := strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n")
+	if c.SkipLines+c.SkipTrailing >= len(lines) {
+		return nil, 0
+	}
+	return lines[c.SkipLines : len(lines)-c.SkipTrailing], c.SkipLines
+}
+
+// sourceProxy assembles a proxy from the parts found in a list entry, a known protocol overrides the list protocol
+func sourceProxy(protocol Protocol, host, port, kind, username, password string) (*Proxy, *ParseError) {
+	if known, ok := schemes[strings.ToLower(kind)]; ok {
+		protocol = known
+	}
+	if port != "" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	proxy, err := ParseWithProtocol(protocol, host)
+	if err != nil {
+		return nil, err.(*ParseError)
+	}
+	return withCredentials(proxy, username, password), nil
+}
@@ -0,0 +1,147 @@
+package groxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceConfig_Provider(t *testing.T) {
+	lists := map[string]string{
+		"/http.txt":   "# header\n1.2.3.4:80 US\nbogus\n",
+		"/socks5.txt": "# header\n5.6.7.8:1080\n",
+		"/list.jsonl": `{"ip":"1.1.1.1","port":8080,"type":"https"}` + "\n" + `{"ip":"2.2.2.2","port":99999}` + "\n",
+		"/list.csv":   "ip;port;user;pass\n3.3.3.3;3128;bob;secret\n",
+		"/list.html":  "<tr><td>4.4.4.4</td><td>8000</td></tr><tr><td>4.4.4.5</td><td>0</td></tr>",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, lists[r.URL.Path])
+	}))
+	defer server.Close()
+
+	config := strings.Replace(`
+sources:
+  - name: lines
+    urls: ["URL/{protocol}.txt"]
+    protocols: [http, socks5]
+    skip_lines: 1
+  - name: jsonl
+    urls: ["URL/list.jsonl"]
+    format: jsonl
+    fields: {host: ip, port: port, protocol: type}
+  - name: csv
+    urls: ["URL/list.csv"]
+    format: csv
+    comma: ";"
+    skip_lines: 1
+    fields: {host: "0", port: "1", username: "2", password: "3"}
+  - name: regex
+    urls: ["URL/list.html"]
+    format: regex
+    pattern: '<td>(?P<host>[\d.]+)</td><td>(?P<port>\d+)</td>'
+`, "URL", server.URL, -1)
+	configs, err := ParseSources([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseSources() error = %v", err)
+	}
+
+	tests := []struct {
+		wantHosts    []string
+		wantProtocol []Protocol
+		wantRejected int
+		// wantSource is where the first host was listed
+		wantSource SourceRef
+	}{
+		{[]string{"1.2.3.4:80", "5.6.7.8:1080"}, []Protocol{HTTP, SOCKS5}, 1, SourceRef{"lines", 2, "1.2.3.4:80 US"}},
+		{[]string{"1.1.1.1:8080"}, []Protocol{HTTPS}, 1,
+			SourceRef{"jsonl", 1, `{"ip":"1.1.1.1","port":8080,"type":"https"}`}},
+		{[]string{"3.3.3.3:3128"}, []Protocol{HTTP}, 0, SourceRef{"csv", 2, "3.3.3.3;3128;bob;secret"}},
+		{[]string{"4.4.4.4:8000"}, []Protocol{HTTP}, 1, SourceRef{"regex", 0, "<td>4.4.4.4</td><td>8000</td>"}},
+	}
+	for i, tt := range tests {
+		t.Run(configs[i].Name, func(t *testing.T) {
+			provider, err := configs[i].Provider()
+			if err != nil {
+				t.Fatalf("Provider() error = %v", err)
+			}
+			resp := provider(context.Background())
+			if resp.Err != nil || resp.Source != configs[i].Name {
+				t.Fatalf("provider() = %v %v", resp.Source, resp.Err)
+			}
+			var hosts []string
+			var protocols []Protocol
+			var sources [][]SourceRef
+			for _, proxy := range Distinct(resp.Proxies) {
+				hosts = append(hosts, proxy.Host())
+				protocols = append(protocols, proxy.Protocol())
+				sources = append(sources, proxy.Sources())
+			}
+			if len(hosts) > 1 && hosts[0] > hosts[1] {
+				hosts[0], hosts[1] = hosts[1], hosts[0]
+				protocols[0], protocols[1] = protocols[1], protocols[0]
+				sources[0], sources[1] = sources[1], sources[0]
+			}
+			if want := []SourceRef{tt.wantSource}; len(sources) == 0 || !reflect.DeepEqual(sources[0], want) {
+				t.Errorf("Sources() = %+v, want %+v", sources, want)
+			}
+			if !reflect.DeepEqual(hosts, tt.wantHosts) || !reflect.DeepEqual(protocols, tt.wantProtocol) {
+				t.Errorf("provider() = %v %v, want %v %v", hosts, protocols, tt.wantHosts, tt.wantProtocol)
+			}
+			if len(resp.Rejected) != tt.wantRejected {
+				t.Errorf("provider() rejected = %v, want %d", resp.Rejected, tt.wantRejected)
+			}
+		})
+	}
+	if proxy := mustProvider(configs[2])(context.Background()).Proxies[0]; proxy.Username() != "bob" {
+		t.Errorf("csv username = %q, want bob", proxy.Username())
+	}
+}
+
+func TestSourceConfig_ProviderInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SourceConfig
+	}{
+		{"no name", SourceConfig{URLs: []string{"http://example.com"}}},
+		{"no urls", SourceConfig{Name: "a"}},
+		{"unknown format", SourceConfig{Name: "a", URLs: []string{"http://example.com"}, Format: "xml"}},
+		{"placeholder without protocols", SourceConfig{Name: "a", URLs: []string{"http://example.com/{protocol}"}}},
+		{"bad csv column", SourceConfig{Name: "a", URLs: []string{"http://example.com"}, Format: FormatCSV,
+			Fields: SourceFields{Host: "ip"}}},
+		{"page placeholder outside a table", SourceConfig{Name: "a", URLs: []string{"http://example.com/{page}"}}},
+		{"negative table pages", SourceConfig{Name: "a", URLs: []string{"http://example.com/{page}"},
+			Format: FormatHTMLTable, Table: TableOptions{Pages: -1, Columns: TableColumns{IP: TableColumn{Name: "ip"}}}}},
+		{"regex without host", SourceConfig{Name: "a", URLs: []string{"http://example.com"}, Format: FormatRegex,
+			Pattern: `(\d+)`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.config.Provider(); err == nil {
+				t.Errorf("Provider() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestSourceConfig_ProviderServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	provider, err := SourceConfig{Name: "down", URLs: []string{server.URL + "/list.txt"}}.Provider()
+	if err != nil {
+		t.Fatalf("Provider() error = %v", err)
+	}
+	resp := provider(context.Background())
+	if resp.Err == nil || !strings.Contains(resp.Err.Error(), "500 Internal Server Error") {
+		t.Errorf("provider() error = %v, want the 500 status", resp.Err)
+	}
+	if len(resp.Proxies) != 0 {
+		t.Errorf("provider() = %v, want no proxies", resp.Proxies)
+	}
+}
@@ -0,0 +1,754 @@
+// Package sqlite implements groxy.Store on top of a SQLite database using a pure Go driver, so no cgo is required
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G5Becks/groxy"
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order when a database is opened, the number applied is kept in PRAGMA user_version
+// Times are stored as unix nanoseconds and durations as nanoseconds
+var migrations = []string{
+	`CREATE TABLE proxies (
+		id            TEXT PRIMARY KEY,
+		protocol      TEXT NOT NULL,
+		host          TEXT NOT NULL,
+		username      TEXT NOT NULL DEFAULT '',
+		password      TEXT NOT NULL DEFAULT '',
+		anonymity     INTEGER NOT NULL DEFAULT 0,
+		response_time INTEGER NOT NULL DEFAULT 0,
+		alive         INTEGER NOT NULL DEFAULT 0,
+		country       TEXT NOT NULL DEFAULT '',
+		last_checked  INTEGER,
+		first_seen    INTEGER NOT NULL,
+		last_seen     INTEGER NOT NULL,
+		UNIQUE (protocol, host)
+	);
+	CREATE INDEX proxies_alive ON proxies (alive, response_time);
+	CREATE INDEX proxies_host ON proxies (host);`,
+	`CREATE TABLE checks (
+		proxy_id  TEXT NOT NULL,
+		time      INTEGER NOT NULL,
+		latency   INTEGER NOT NULL,
+		ok        INTEGER NOT NULL,
+		err_class TEXT NOT NULL DEFAULT '',
+		target    TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX checks_proxy_time ON checks (proxy_id, time);`,
+	`CREATE TABLE targets (
+		proxy_id TEXT NOT NULL,
+		name     TEXT NOT NULL,
+		usable   INTEGER NOT NULL,
+		banned   INTEGER NOT NULL,
+		latency  INTEGER NOT NULL,
+		checked  INTEGER NOT NULL,
+		PRIMARY KEY (proxy_id, name)
+	);`,
+	`ALTER TABLE proxies ADD COLUMN connect_time INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE proxies ADD COLUMN handshake_time INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE proxies ADD COLUMN tls_time INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE proxies ADD COLUMN first_byte_time INTEGER NOT NULL DEFAULT 0;`,
+	`CREATE TABLE tags (
+		proxy_id TEXT NOT NULL,
+		tag      TEXT NOT NULL,
+		PRIMARY KEY (proxy_id, tag)
+	);
+	CREATE INDEX tags_tag ON tags (tag);`,
+	`ALTER TABLE proxies ADD COLUMN city TEXT NOT NULL DEFAULT '';
+	ALTER TABLE proxies ADD COLUMN asn INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE proxies ADD COLUMN org TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE sightings (
+		proxy_id   TEXT NOT NULL,
+		source     TEXT NOT NULL,
+		first_seen INTEGER NOT NULL,
+		last_seen  INTEGER NOT NULL,
+		PRIMARY KEY (proxy_id, source)
+	);`,
+	`CREATE TABLE sources (
+		proxy_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		line     INTEGER NOT NULL,
+		raw      TEXT NOT NULL,
+		PRIMARY KEY (proxy_id, provider)
+	);`,
+	`CREATE TABLE declared (
+		proxy_id TEXT NOT NULL,
+		name     TEXT NOT NULL,
+		value    TEXT NOT NULL,
+		PRIMARY KEY (proxy_id, name)
+	);`,
+}
+
+const proxyColumns = `id, protocol, host, username, password, anonymity, response_time, alive, country, last_checked,
+	connect_time, handshake_time, tls_time, first_byte_time, city, asn, org`
+
+// Store is a groxy.Store persisting proxies in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+var _ groxy.Store = (*Store)(nil)
+
+// Open opens the SQLite database at path, creating it if needed, and migrates it to the latest schema
+// Use ":memory:" for a database which lives as long as the store
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection avoids SQLITE_BUSY errors between writers and keeps in-memory databases alive
+	db.SetMaxOpenConns(1)
+	store := &Store{db: db}
+	if err := store.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrate applies the migrations the database has not seen yet
+func (s *Store) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.ExecContext(ctx, `PRAGMA user_version = `+strconv.Itoa(i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkColumns are the columns set by checking a proxy
+var checkColumns = []string{`anonymity`, `response_time`, `alive`, `country`, `last_checked`, `connect_time`,
+	`handshake_time`, `tls_time`, `first_byte_time`, `city`, `asn`, `org`}
+
+// keepChecked returns the assignments of an upsert updating columns only when the new row was checked, so a proxy
+// harvested again does not erase the outcome of the last check
+func keepChecked(columns []string) string {
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		assignments = append(assignments, column+` = CASE WHEN excluded.last_checked IS NULL THEN proxies.`+column+
+			` ELSE excluded.`+column+` END`)
+	}
+	return strings.Join(assignments, `,
+			`)
+}
+
+// Upsert inserts proxies or updates the stored proxies with the same protocol and host, so a proxy harvested again
+// updates the stored one rather than being stored twice. The stored Id and first sighting are kept, the Id of the
+// in-memory proxy then differs from the stored one, look it up with Find. A proxy which was never checked leaves the
+// outcome of the last stored check untouched. Checks in a proxy's history newer than the ones already stored are
+// appended, so the stored history keeps growing beyond what a proxy remembers in memory
+func (s *Store) Upsert(ctx context.Context, proxies ...*groxy.Proxy) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO proxies (`+proxyColumns+`, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (protocol, host) DO UPDATE SET
+			username = excluded.username,
+			password = excluded.password,
+			`+keepChecked(checkColumns)+`,
+			last_seen = excluded.last_seen
+		RETURNING id`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UnixNano()
+	for _, proxy := range proxies {
+		r := proxy.Record()
+		err := stmt.QueryRowContext(ctx, r.ID, string(r.Protocol), r.Host, r.Username, r.Password, int(r.Anonymity),
+			int64(r.ResponseTime), r.Alive, r.Country, nullTime(r.LastChecked), int64(r.Timing.Connect),
+			int64(r.Timing.Handshake), int64(r.Timing.TLS), int64(r.Timing.FirstByte), r.City, int64(r.ASN), r.Org, now,
+			now).Scan(&r.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := appendChecks(ctx, tx, r.ID, r.History); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := upsertTargets(ctx, tx, r.ID, r.Targets); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := replaceTags(ctx, tx, r.ID, r.Tags); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := upsertSightings(ctx, tx, r.ID, r.Sightings); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := upsertSources(ctx, tx, r.ID, r.Sources); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := upsertDeclared(ctx, tx, r.ID, r.Declared); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// upsertSightings stores when each source listed the proxy, keeping the earliest first sighting and latest last one
+func upsertSightings(ctx context.Context, tx *sql.Tx, id string, sightings []groxy.Sighting) error {
+	for _, sighting := range sightings {
+		_, err := tx.ExecContext(ctx, `INSERT INTO sightings (proxy_id, source, first_seen, last_seen)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (proxy_id, source) DO UPDATE SET
+				first_seen = MIN(first_seen, excluded.first_seen),
+				last_seen = MAX(last_seen, excluded.last_seen)`,
+			id, sighting.Source, sighting.FirstSeen.UnixNano(), sighting.LastSeen.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSightings attaches to the records of batch when each source listed them, sorted by source
+func (s *Store) loadSightings(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, source, first_seen, last_seen FROM sightings
+		WHERE proxy_id IN (`+batch.in+`) ORDER BY source`, batch.ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var sighting groxy.Sighting
+		var first, last int64
+		if err := rows.Scan(&id, &sighting.Source, &first, &last); err != nil {
+			return err
+		}
+		sighting.FirstSeen = time.Unix(0, first)
+		sighting.LastSeen = time.Unix(0, last)
+		r := batch.records[id]
+		r.Sightings = append(r.Sightings, sighting)
+	}
+	return rows.Err()
+}
+
+// upsertSources stores where the proxy was listed, replacing the stored source of each provider, the sources of other
+// providers are kept
+func upsertSources(ctx context.Context, tx *sql.Tx, id string, sources []groxy.SourceRef) error {
+	for _, source := range sources {
+		_, err := tx.ExecContext(ctx, `INSERT INTO sources (proxy_id, provider, line, raw) VALUES (?, ?, ?, ?)
+			ON CONFLICT (proxy_id, provider) DO UPDATE SET line = excluded.line, raw = excluded.raw`,
+			id, source.Provider, source.Line, source.Raw)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSources attaches to the records of batch where they were listed, in the order the sources were stored
+func (s *Store) loadSources(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, provider, line, raw FROM sources
+		WHERE proxy_id IN (`+batch.in+`) ORDER BY rowid`, batch.ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var source groxy.SourceRef
+		if err := rows.Scan(&id, &source.Provider, &source.Line, &source.Raw); err != nil {
+			return err
+		}
+		r := batch.records[id]
+		r.Sources = append(r.Sources, source)
+	}
+	return rows.Err()
+}
+
+// upsertDeclared stores the attributes claimed by the sources of the proxy, replacing the stored value of each of them
+func upsertDeclared(ctx context.Context, tx *sql.Tx, id string, declared map[string]string) error {
+	for name, value := range declared {
+		_, err := tx.ExecContext(ctx, `INSERT INTO declared (proxy_id, name, value) VALUES (?, ?, ?)
+			ON CONFLICT (proxy_id, name) DO UPDATE SET value = excluded.value`, id, name, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDeclared attaches to the records of batch the attributes claimed by their sources
+func (s *Store) loadDeclared(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, name, value FROM declared
+		WHERE proxy_id IN (`+batch.in+`)`, batch.ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name, value string
+		if err := rows.Scan(&id, &name, &value); err != nil {
+			return err
+		}
+		r := batch.records[id]
+		if r.Declared == nil {
+			r.Declared = make(map[string]string)
+		}
+		r.Declared[name] = value
+	}
+	return rows.Err()
+}
+
+// replaceTags replaces the stored tags of the proxy with tags
+func replaceTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE proxy_id = ?`, id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tags (proxy_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags attaches to the records of batch their tags, sorted
+func (s *Store) loadTags(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, tag FROM tags WHERE proxy_id IN (`+batch.in+`) ORDER BY tag`,
+		batch.ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		r := batch.records[id]
+		r.Tags = append(r.Tags, tag)
+	}
+	return rows.Err()
+}
+
+// upsertTargets stores the status of the proxy for each target, targets the proxy no longer knows are kept
+func upsertTargets(ctx context.Context, tx *sql.Tx, id string, targets map[string]groxy.TargetStatus) error {
+	for name, status := range targets {
+		_, err := tx.ExecContext(ctx, `INSERT INTO targets (proxy_id, name, usable, banned, latency, checked)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (proxy_id, name) DO UPDATE SET
+				usable = excluded.usable,
+				banned = excluded.banned,
+				latency = excluded.latency,
+				checked = excluded.checked`,
+			id, name, status.Usable, status.Banned, int64(status.Latency), status.Checked.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTargets attaches to the records of batch their status for each target they were checked against
+func (s *Store) loadTargets(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, name, usable, banned, latency, checked FROM targets
+		WHERE proxy_id IN (`+batch.in+`)`, batch.ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		var status groxy.TargetStatus
+		var latency, checked int64
+		if err := rows.Scan(&id, &name, &status.Usable, &status.Banned, &latency, &checked); err != nil {
+			return err
+		}
+		status.Latency = time.Duration(latency)
+		status.Checked = time.Unix(0, checked)
+		r := batch.records[id]
+		if r.Targets == nil {
+			r.Targets = make(map[string]groxy.TargetStatus)
+		}
+		r.Targets[name] = status
+	}
+	return rows.Err()
+}
+
+// appendChecks stores the records newer than the last check stored for the proxy
+func appendChecks(ctx context.Context, tx *sql.Tx, id string, history []groxy.CheckRecord) error {
+	if len(history) == 0 {
+		return nil
+	}
+	var last sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(time) FROM checks WHERE proxy_id = ?`, id).Scan(&last); err != nil {
+		return err
+	}
+	for _, record := range history {
+		if last.Valid && record.Time.UnixNano() <= last.Int64 {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO checks (proxy_id, time, latency, ok, err_class, target)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			id, record.Time.UnixNano(), int64(record.Latency), record.OK, record.ErrClass, record.Target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History returns every check stored for the proxy with the given Id, oldest first
+func (s *Store) History(ctx context.Context, id string) ([]groxy.CheckRecord, error) {
+	return s.queryChecks(ctx, `SELECT time, latency, ok, err_class, target FROM checks
+		WHERE proxy_id = ? ORDER BY time`, id)
+}
+
+// loadRecentChecks attaches to the records of batch the checks a proxy remembers in memory, the most recent
+// DefaultHistorySize of each, oldest first
+func (s *Store) loadRecentChecks(ctx context.Context, batch *recordBatch) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT proxy_id, time, latency, ok, err_class, target FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY proxy_id ORDER BY time DESC) AS recent FROM checks
+			WHERE proxy_id IN (`+batch.in+`)
+		) WHERE recent <= ? ORDER BY time`, append(batch.ids, groxy.DefaultHistorySize)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var record groxy.CheckRecord
+		var t, latency int64
+		if err := rows.Scan(&id, &t, &latency, &record.OK, &record.ErrClass, &record.Target); err != nil {
+			return err
+		}
+		record.Time = time.Unix(0, t)
+		record.Latency = time.Duration(latency)
+		r := batch.records[id]
+		r.History = append(r.History, record)
+	}
+	return rows.Err()
+}
+
+// maxBatch is the number of proxies whose related rows are loaded by a single query, it keeps the number of bound
+// parameters well below the SQLite limit
+const maxBatch = 500
+
+// recordBatch indexes records by id for the queries loading their related rows
+type recordBatch struct {
+	records map[string]*groxy.Record
+	ids     []interface{}
+	in      string
+}
+
+// loadRelated attaches to records their recent history, targets, tags, sightings, sources and declared attributes
+// with one query per table for every maxBatch records
+func (s *Store) loadRelated(ctx context.Context, records []groxy.Record) error {
+	loaders := []func(context.Context, *recordBatch) error{
+		s.loadRecentChecks, s.loadTargets, s.loadTags, s.loadSightings, s.loadSources, s.loadDeclared,
+	}
+	for start := 0; start < len(records); start += maxBatch {
+		end := start + maxBatch
+		if end > len(records) {
+			end = len(records)
+		}
+		batch := &recordBatch{records: make(map[string]*groxy.Record), in: placeholders(end - start)}
+		for i := start; i < end; i++ {
+			batch.records[records[i].ID] = &records[i]
+			batch.ids = append(batch.ids, records[i].ID)
+		}
+		for _, load := range loaders {
+			if err := load(ctx, batch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) queryChecks(ctx context.Context, query string, args ...interface{}) ([]groxy.CheckRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []groxy.CheckRecord
+	for rows.Next() {
+		var record groxy.CheckRecord
+		var t, latency int64
+		if err := rows.Scan(&t, &latency, &record.OK, &record.ErrClass, &record.Target); err != nil {
+			return nil, err
+		}
+		record.Time = time.Unix(0, t)
+		record.Latency = time.Duration(latency)
+		history = append(history, record)
+	}
+	return history, rows.Err()
+}
+
+// Get returns the proxy with the given Id, or groxy.ErrNotFound
+func (s *Store) Get(ctx context.Context, id string) (*groxy.Proxy, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+proxyColumns+` FROM proxies WHERE id = ?`, id)
+	r, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, groxy.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := []groxy.Record{r}
+	if err := s.loadRelated(ctx, records); err != nil {
+		return nil, err
+	}
+	return groxy.FromRecord(records[0]), nil
+}
+
+// Find returns the stored proxies matching filter in the order of filter.Sort, alive and fastest first when it is empty
+// Scores are computed from the recent history of each proxy, filters on MinScore or sorting by score or by declared
+// attributes are applied once the matching proxies were loaded
+func (s *Store) Find(ctx context.Context, filter groxy.Filter) ([]*groxy.Proxy, error) {
+	where, args := whereClause(filter)
+	order, byScore := orderClause(filter.Sort)
+	query := `SELECT ` + proxyColumns + ` FROM proxies` + where + order
+	if filter.Limit > 0 && !byScore && filter.MinScore <= 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	records, err := s.queryRecords(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the related rows are loaded once the rows are closed since the store uses a single connection
+	if err := s.loadRelated(ctx, records); err != nil {
+		return nil, err
+	}
+	var proxies []*groxy.Proxy
+	for _, r := range records {
+		proxies = append(proxies, groxy.FromRecord(r))
+	}
+	if byScore || filter.MinScore > 0 {
+		proxies = groxy.Filter{MinScore: filter.MinScore, Sort: filter.Sort, Limit: filter.Limit}.Apply(proxies)
+	}
+	return proxies, nil
+}
+
+func (s *Store) queryRecords(ctx context.Context, query string, args ...interface{}) ([]groxy.Record, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []groxy.Record
+	for rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// DeleteStale deletes the proxies which were not checked since before, proxies never checked are judged by when they
+// were first stored. The history, target statuses, tags, sightings, sources and declared attributes of the deleted
+// proxies are deleted with them
+func (s *Store) DeleteStale(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM proxies WHERE COALESCE(last_checked, first_seen) < ?`,
+		before.UnixNano())
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checks WHERE proxy_id NOT IN (SELECT id FROM proxies)`); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for _, table := range []string{`targets`, `tags`, `sightings`, `sources`, `declared`} {
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE proxy_id NOT IN (SELECT id FROM proxies)`)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// whereClause translates filter into a sql WHERE clause and its arguments
+func whereClause(filter groxy.Filter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if filter.Alive != nil {
+		conds = append(conds, `alive = ?`)
+		args = append(args, *filter.Alive)
+	}
+	if filter.MinAnonymity > groxy.AnonymityUnknown {
+		conds = append(conds, `anonymity >= ?`)
+		args = append(args, int(filter.MinAnonymity))
+	}
+	if filter.MaxLatency > 0 {
+		conds = append(conds, `response_time <= ?`)
+		args = append(args, int64(filter.MaxLatency))
+	}
+	if len(filter.Protocols) > 0 {
+		conds = append(conds, `protocol IN (`+placeholders(len(filter.Protocols))+`)`)
+		for _, protocol := range filter.Protocols {
+			args = append(args, string(protocol))
+		}
+	}
+	if len(filter.Countries) > 0 {
+		conds = append(conds, `country COLLATE NOCASE IN (`+placeholders(len(filter.Countries))+`)`)
+		for _, country := range filter.Countries {
+			args = append(args, country)
+		}
+	}
+	if len(filter.ASNs) > 0 {
+		conds = append(conds, `asn IN (`+placeholders(len(filter.ASNs))+`)`)
+		for _, asn := range filter.ASNs {
+			args = append(args, int64(asn))
+		}
+	}
+	if len(filter.ExcludeASNs) > 0 {
+		conds = append(conds, `asn NOT IN (`+placeholders(len(filter.ExcludeASNs))+`)`)
+		for _, asn := range filter.ExcludeASNs {
+			args = append(args, int64(asn))
+		}
+	}
+	phases := []struct {
+		column string
+		max    time.Duration
+	}{
+		{`connect_time`, filter.MaxTiming.Connect},
+		{`handshake_time`, filter.MaxTiming.Handshake},
+		{`tls_time`, filter.MaxTiming.TLS},
+		{`first_byte_time`, filter.MaxTiming.FirstByte},
+		{`response_time`, filter.MaxTiming.Total},
+	}
+	for _, phase := range phases {
+		if phase.max > 0 {
+			conds = append(conds, phase.column+` <= ?`)
+			args = append(args, int64(phase.max))
+		}
+	}
+	for _, target := range filter.Targets {
+		conds = append(conds, `EXISTS (SELECT 1 FROM targets WHERE targets.proxy_id = proxies.id AND name = ? AND usable)`)
+		args = append(args, target)
+	}
+	for _, tag := range filter.Tags {
+		conds = append(conds, `EXISTS (SELECT 1 FROM tags WHERE tags.proxy_id = proxies.id AND tag = ?)`)
+		args = append(args, tag)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conds, ` AND `), args
+}
+
+// orderClause translates sort keys into a sql ORDER BY clause, byScore is true when a key cannot be sorted by in sql
+// and the rows must be sorted once loaded
+func orderClause(keys []groxy.SortKey) (order string, byScore bool) {
+	if len(keys) == 0 {
+		return ` ORDER BY alive DESC, response_time ASC`, false
+	}
+	var terms []string
+	for _, key := range keys {
+		switch key {
+		case groxy.SortLatency:
+			terms = append(terms, `response_time = 0`, `response_time ASC`)
+		case groxy.SortLastChecked:
+			terms = append(terms, `last_checked IS NULL`, `last_checked DESC`)
+		case groxy.SortScore, groxy.SortDeclared:
+			byScore = true
+		}
+	}
+	if len(terms) == 0 {
+		return "", byScore
+	}
+	return ` ORDER BY ` + strings.Join(terms, `, `), byScore
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner) (groxy.Record, error) {
+	var r groxy.Record
+	var protocol string
+	var anonymity int
+	var responseTime int64
+	var lastChecked sql.NullInt64
+	var connect, handshake, tls, firstByte, asn int64
+	err := row.Scan(&r.ID, &protocol, &r.Host, &r.Username, &r.Password, &anonymity, &responseTime, &r.Alive,
+		&r.Country, &lastChecked, &connect, &handshake, &tls, &firstByte, &r.City, &asn, &r.Org)
+	if err != nil {
+		return r, err
+	}
+	r.Protocol = groxy.Protocol(protocol)
+	r.Anonymity = groxy.AnonymityLevel(anonymity)
+	r.ResponseTime = time.Duration(responseTime)
+	r.ASN = uint(asn)
+	r.Timing = groxy.Timing{
+		Connect:   time.Duration(connect),
+		Handshake: time.Duration(handshake),
+		TLS:       time.Duration(tls),
+		FirstByte: time.Duration(firstByte),
+		Total:     r.ResponseTime,
+	}
+	if lastChecked.Valid {
+		r.LastChecked = time.Unix(0, lastChecked.Int64)
+	}
+	return r, nil
+}
+
+func nullTime(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
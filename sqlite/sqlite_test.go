@@ -0,0 +1,254 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/G5Becks/groxy"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "proxies.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fast := groxy.FromRecord(groxy.Record{Protocol: groxy.SOCKS5, Host: "1.1.1.1:1080", Username: "user", Password: "pass",
+		Anonymity: groxy.Elite, ResponseTime: 100 * time.Millisecond, Alive: true, LastChecked: time.Now(), Country: "US",
+		City: "Ashburn", ASN: 16509, Org: "AMAZON-02",
+		Timing: groxy.Timing{Connect: 10 * time.Millisecond, Handshake: 20 * time.Millisecond,
+			FirstByte: 50 * time.Millisecond}})
+	slow := groxy.FromRecord(groxy.Record{Protocol: groxy.HTTP, Host: "2.2.2.2:8080", Anonymity: groxy.Transparent,
+		ResponseTime: 2 * time.Second, Alive: true, LastChecked: time.Now(), Country: "DE", Tags: []string{"paid"},
+		Sightings: []groxy.Sighting{{Source: "spys", FirstSeen: time.Unix(100, 0), LastSeen: time.Unix(200, 0)}},
+		Sources:   []groxy.SourceRef{{Provider: "spys", Line: 7, Raw: "2.2.2.2:8080 DE-N +"}},
+		Declared:  map[string]string{groxy.AttrAnonymity: "transparent", groxy.AttrCountry: "DE"},
+		Targets: map[string]groxy.TargetStatus{
+			"shop":   {Usable: true, Latency: time.Second, Checked: time.Now()},
+			"search": {Banned: true, Checked: time.Now()},
+		}})
+	stale := groxy.FromRecord(groxy.Record{Protocol: groxy.HTTP, Host: "3.3.3.3:8080",
+		LastChecked: time.Now().Add(-48 * time.Hour)})
+	fast.History().Add(groxy.CheckRecord{Time: time.Now().Add(-time.Minute), Latency: time.Second, OK: true})
+	if err := store.Upsert(ctx, fast, slow, stale); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	// upserting again only appends the checks which are not stored yet
+	fast.History().Add(groxy.CheckRecord{Time: time.Now(), ErrClass: "timeout", Target: "http://example.com"})
+	if err := store.Upsert(ctx, fast); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if history, err := store.History(ctx, fast.Id()); err != nil || len(history) != 2 {
+		t.Errorf("History() = %v, %v, want 2 checks", history, err)
+	}
+
+	got, err := store.Get(ctx, fast.Id())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Host() != fast.Host() || got.Protocol() != groxy.SOCKS5 || got.Password() != "pass" ||
+		got.Anonymity() != groxy.Elite || got.ResponseTime() != fast.ResponseTime() || got.Country() != "US" ||
+		got.Timing() != fast.Timing() || got.Geo() != fast.Geo() {
+		t.Errorf("Get() = %+v, want %+v", got.Record(), fast.Record())
+	}
+	if got.Reliability().SuccessRatio != 0.5 {
+		t.Errorf("Get() reliability = %+v, want success ratio 0.5", got.Reliability())
+	}
+	if got, err := store.Get(ctx, slow.Id()); err != nil || !got.WorksFor("shop") || got.WorksFor("search") {
+		t.Errorf("Get() targets = %+v, %v, want usable for shop only", got.Targets(), err)
+	}
+	// sightings of a source are merged with the stored ones
+	relisted := slow.Record()
+	relisted.Sightings = []groxy.Sighting{{Source: "spys", FirstSeen: time.Unix(150, 0), LastSeen: time.Unix(300, 0)}}
+	relisted.Sources = []groxy.SourceRef{{Provider: "fate0", Line: 3},
+		{Provider: "spys", Line: 9, Raw: "2.2.2.2:8080 DE-A +"}}
+	if err := store.Upsert(ctx, groxy.FromRecord(relisted)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if got, err := store.Get(ctx, slow.Id()); err != nil || len(got.Sightings()) != 1 ||
+		!got.FirstSeen().Equal(time.Unix(100, 0)) || !got.LastSeen().Equal(time.Unix(300, 0)) {
+		t.Errorf("Get() sightings = %+v, %v, want first seen at 100 and last at 300", got.Sightings(), err)
+	}
+	// a provider listing the proxy again replaces its source rather than adding one
+	wantSources := []groxy.SourceRef{{Provider: "spys", Line: 9, Raw: "2.2.2.2:8080 DE-A +"},
+		{Provider: "fate0", Line: 3}}
+	if got, err := store.Get(ctx, slow.Id()); err != nil || !reflect.DeepEqual(got.Sources(), wantSources) {
+		t.Errorf("Get() sources = %+v, %v, want %+v", got.Sources(), err, wantSources)
+	}
+	if got, err := store.Get(ctx, slow.Id()); err != nil || !reflect.DeepEqual(got.Declared(), slow.Declared()) {
+		t.Errorf("Get() declared = %v, %v, want %v", got.Declared(), err, slow.Declared())
+	}
+	if _, err := store.Get(ctx, groxy.NewID().String()); err != groxy.ErrNotFound {
+		t.Errorf("Get() error = %v, want %v", err, groxy.ErrNotFound)
+	}
+
+	alive := true
+	tests := []struct {
+		name   string
+		filter groxy.Filter
+		want   []string
+	}{
+		{"everything", groxy.Filter{}, []string{fast.Id(), slow.Id(), stale.Id()}},
+		{"alive fastest first", groxy.Filter{Alive: &alive}, []string{fast.Id(), slow.Id()}},
+		{"anonymous", groxy.Filter{MinAnonymity: groxy.Anonymous}, []string{fast.Id()}},
+		{"latency", groxy.Filter{MaxLatency: time.Second, Alive: &alive}, []string{fast.Id()}},
+		{"protocol", groxy.Filter{Protocols: []groxy.Protocol{groxy.HTTP}, Alive: &alive}, []string{slow.Id()}},
+		{"country", groxy.Filter{Countries: []string{"de"}}, []string{slow.Id()}},
+		{"handshake phase", groxy.Filter{MaxTiming: groxy.Timing{Handshake: 50 * time.Millisecond}, Alive: &alive},
+			[]string{fast.Id(), slow.Id()}},
+		{"first byte phase", groxy.Filter{MaxTiming: groxy.Timing{FirstByte: 40 * time.Millisecond}, Alive: &alive},
+			[]string{slow.Id()}},
+		{"usable for target", groxy.Filter{Targets: []string{"shop"}}, []string{slow.Id()}},
+		{"banned by target", groxy.Filter{Targets: []string{"search"}}, nil},
+		{"limit", groxy.Filter{Alive: &alive, Limit: 1}, []string{fast.Id()}},
+		{"asn", groxy.Filter{ASNs: []uint{16509}}, []string{fast.Id()}},
+		{"exclude asn", groxy.Filter{ExcludeASNs: []uint{16509}, Alive: &alive}, []string{slow.Id()}},
+		{"tag", groxy.Filter{Tags: []string{"paid"}}, []string{slow.Id()}},
+		{"missing tag", groxy.Filter{Tags: []string{"paid", "free"}}, nil},
+		{"sort by latency", groxy.Filter{Sort: []groxy.SortKey{groxy.SortLatency}},
+			[]string{fast.Id(), slow.Id(), stale.Id()}},
+		{"top by score", groxy.Filter{Sort: []groxy.SortKey{groxy.SortScore, groxy.SortLatency}, Limit: 2},
+			[]string{fast.Id(), slow.Id()}},
+		{"min score", groxy.Filter{MinScore: 0.01}, []string{fast.Id()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := store.Find(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			var ids []string
+			for _, proxy := range proxies {
+				ids = append(ids, proxy.Id())
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("Find() = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("Find() = %v, want %v", ids, tt.want)
+				}
+			}
+			if mem := tt.filter.Apply([]*groxy.Proxy{fast, slow, stale}); len(mem) != len(tt.want) {
+				t.Errorf("Filter.Apply() = %v proxies, want %v", len(mem), len(tt.want))
+			}
+		})
+	}
+
+	deleted, err := store.DeleteStale(ctx, time.Now().Add(-time.Hour))
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteStale() = %v, %v, want 1", deleted, err)
+	}
+	store.Close()
+
+	// reopening must not run the migrations again
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() existing database error = %v", err)
+	}
+	defer store.Close()
+	if proxies, _ := store.Find(ctx, groxy.Filter{}); len(proxies) != 2 {
+		t.Errorf("Find() after reopen = %v proxies, want 2", len(proxies))
+	}
+}
+
+func TestStore_Upsert_harvestedAgain(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	checked := time.Now().Truncate(time.Millisecond)
+	first := groxy.FromRecord(groxy.Record{Protocol: groxy.HTTP, Host: "4.4.4.4:8080", Alive: true,
+		Anonymity: groxy.Elite, ResponseTime: 300 * time.Millisecond, LastChecked: checked, Country: "NL",
+		Timing: groxy.Timing{Connect: 100 * time.Millisecond}})
+	if err := store.Upsert(ctx, first); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	// a second harvest parses the same proxy under a new id and without a check
+	again := groxy.FromRecord(groxy.Record{Protocol: groxy.HTTP, Host: "4.4.4.4:8080", Tags: []string{"paid"}})
+	if err := store.Upsert(ctx, again); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if deleted, err := store.DeleteStale(ctx, checked.Add(-time.Hour)); err != nil || deleted != 0 {
+		t.Errorf("DeleteStale() = %v, %v, want the checked proxy kept", deleted, err)
+	}
+	proxies, err := store.Find(ctx, groxy.Filter{})
+	if err != nil || len(proxies) != 1 {
+		t.Fatalf("Find() = %v proxies, %v, want 1", len(proxies), err)
+	}
+	got := proxies[0]
+	if got.Id() != first.Id() || !got.HasTag("paid") {
+		t.Errorf("Find() = %+v, want %s updated by the second harvest", got.Record(), first.Id())
+	}
+	if !got.Alive() || got.Anonymity() != groxy.Elite || got.ResponseTime() != first.ResponseTime() ||
+		!got.LastChecked().Equal(checked) || got.Country() != "NL" || got.Timing() != first.Timing() {
+		t.Errorf("Find() = %+v, want the check state of %+v", got.Record(), first.Record())
+	}
+
+	// a new check replaces the stored one
+	recheck := again.Record()
+	recheck.Alive, recheck.LastChecked = false, checked.Add(time.Minute)
+	if err := store.Upsert(ctx, groxy.FromRecord(recheck)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if got, err := store.Get(ctx, first.Id()); err != nil || got.Alive() || !got.LastChecked().Equal(recheck.LastChecked) {
+		t.Errorf("Get() = %+v, %v, want the new check", got, err)
+	}
+}
+
+func TestStore_Find_batches(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var proxies []*groxy.Proxy
+	for i := 0; i < maxBatch+10; i++ {
+		proxy := groxy.FromRecord(groxy.Record{Protocol: groxy.HTTP, Host: fmt.Sprintf("10.0.%d.%d:80", i/256, i%256),
+			Tags: []string{"batch"}, Sources: []groxy.SourceRef{{Provider: "list", Line: i + 1}}})
+		proxy.History().Add(groxy.CheckRecord{Time: time.Unix(1, 0), OK: true})
+		proxies = append(proxies, proxy)
+	}
+	if err := store.Upsert(ctx, proxies...); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	// more checks are stored than a proxy remembers
+	for i := 2; i < groxy.DefaultHistorySize+5; i++ {
+		_, err := store.db.Exec(`INSERT INTO checks (proxy_id, time, latency, ok) VALUES (?, ?, 0, 0)`,
+			proxies[0].Id(), time.Unix(int64(i), 0).UnixNano())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := store.Find(ctx, groxy.Filter{})
+	if err != nil || len(found) != len(proxies) {
+		t.Fatalf("Find() = %v proxies, %v, want %v", len(found), err, len(proxies))
+	}
+	for _, proxy := range found {
+		if !proxy.HasTag("batch") || len(proxy.Sources()) != 1 || proxy.History().Len() == 0 {
+			t.Fatalf("Find() proxy = %+v, want its tags, sources and history", proxy.Record())
+		}
+	}
+	got, err := store.Get(ctx, proxies[0].Id())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	history := got.History().Records()
+	last := time.Unix(int64(groxy.DefaultHistorySize+4), 0)
+	if len(history) != groxy.DefaultHistorySize || !history[len(history)-1].Time.Equal(last) {
+		t.Errorf("Get() history = %v checks, want the %v most recent ending at %v", len(history),
+			groxy.DefaultHistorySize, last)
+	}
+}
@@ -0,0 +1,154 @@
+package groxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by a Store when no proxy has the requested id
+var ErrNotFound = errors.New("groxy: proxy not found")
+
+// Store persists proxies so harvested and checked proxies survive restarts, see the sqlite package for an implementation
+type Store interface {
+	// Upsert inserts proxies or updates the stored proxies with the same protocol and host, which keep their Id, so
+	// the Id of a proxy upserted after its host was stored differs from the stored one
+	Upsert(ctx context.Context, proxies ...*Proxy) error
+	// Get returns the proxy with the given Id, or ErrNotFound
+	Get(ctx context.Context, id string) (*Proxy, error)
+	// Find returns the stored proxies matching filter
+	Find(ctx context.Context, filter Filter) ([]*Proxy, error)
+	// DeleteStale deletes the proxies which were not checked since before, proxies never checked are judged by when
+	// they were first stored. It returns the number of proxies deleted
+	DeleteStale(ctx context.Context, before time.Time) (int, error)
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// Filter selects proxies from a Store or a list, the zero value matches every proxy
+type Filter struct {
+	// Alive matches proxies whose Alive value is the one pointed to, nil matches both
+	Alive *bool
+	// MinAnonymity matches proxies at least as anonymous
+	MinAnonymity AnonymityLevel
+	// MaxLatency matches proxies with a ResponseTime no greater, zero disables the filter
+	MaxLatency time.Duration
+	// Protocols matches proxies using any of the protocols, empty matches every protocol
+	Protocols []Protocol
+	// Countries matches proxies located in any of the countries, empty matches every country
+	Countries []string
+	// ASNs matches proxies whose address is announced by any of the autonomous systems, empty matches every one
+	ASNs []uint
+	// ExcludeASNs matches proxies whose address is not announced by any of the autonomous systems, such as the ones of
+	// datacenters, proxies with an unknown ASN match
+	ExcludeASNs []uint
+	// Targets matches proxies usable for every one of the targets, see Proxy.WorksFor
+	Targets []string
+	// MaxTiming matches proxies whose Timing phases are no longer than its non zero phases
+	MaxTiming Timing
+	// MinScore matches proxies whose Reliability score is at least MinScore
+	MinScore float64
+	// Tags matches proxies having every one of the tags, see Proxy.AddTags
+	Tags []string
+	// Sort orders the matches by each key in turn, empty keeps the order of the list, stores pick their own order
+	Sort []SortKey
+	// Limit caps the number of proxies returned once sorted, zero returns every match
+	Limit int
+}
+
+// clone returns a copy of the filter which shares no slices with it
+func (f Filter) clone() Filter {
+	f.Protocols = append([]Protocol(nil), f.Protocols...)
+	f.Countries = append([]string(nil), f.Countries...)
+	f.ASNs = append([]uint(nil), f.ASNs...)
+	f.ExcludeASNs = append([]uint(nil), f.ExcludeASNs...)
+	f.Targets = append([]string(nil), f.Targets...)
+	f.Tags = append([]string(nil), f.Tags...)
+	f.Sort = append([]SortKey(nil), f.Sort...)
+	return f
+}
+
+// Match returns whether proxy is selected by the filter, Limit is ignored
+func (f Filter) Match(proxy *Proxy) bool {
+	if f.Alive != nil && proxy.Alive() != *f.Alive {
+		return false
+	}
+	if proxy.Anonymity() < f.MinAnonymity {
+		return false
+	}
+	if f.MaxLatency > 0 && proxy.ResponseTime() > f.MaxLatency {
+		return false
+	}
+	if !proxy.Timing().within(f.MaxTiming) {
+		return false
+	}
+	if len(f.Protocols) > 0 {
+		found := false
+		for _, protocol := range f.Protocols {
+			if proxy.Protocol() == protocol {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Countries) > 0 {
+		found := false
+		for _, country := range f.Countries {
+			if strings.EqualFold(proxy.Country(), country) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	asn := proxy.Geo().ASN
+	if len(f.ASNs) > 0 && !containsASN(f.ASNs, asn) {
+		return false
+	}
+	if asn != 0 && containsASN(f.ExcludeASNs, asn) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !proxy.HasTag(tag) {
+			return false
+		}
+	}
+	if f.MinScore > 0 && proxy.Reliability().Score < f.MinScore {
+		return false
+	}
+	return worksForAll(proxy, f.Targets)
+}
+
+// containsASN returns whether asns contains asn
+func containsASN(asns []uint, asn uint) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the proxies in list matching the filter sorted by Sort, at most Limit are returned
+func (f Filter) Apply(proxies []*Proxy) []*Proxy {
+	var list []*Proxy
+	for _, proxy := range proxies {
+		if f.Limit > 0 && len(f.Sort) == 0 && len(list) >= f.Limit {
+			break
+		}
+		if f.Match(proxy) {
+			list = append(list, proxy)
+		}
+	}
+	sortProxies(list, f.Sort)
+	if f.Limit > 0 && len(list) > f.Limit {
+		list = list[:f.Limit]
+	}
+	return list
+}
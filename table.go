@@ -0,0 +1,273 @@
+package groxy
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FormatHTMLTable reads proxies from the rows of html tables, Table describes the columns and pagination
+const FormatHTMLTable = "html"
+
+// defaultMaxPages is the number of pages followed through NextLink when MaxPages is not set
+const defaultMaxPages = 10
+
+// TableOptions describes proxy lists published as html tables
+type TableOptions struct {
+	// Selector picks the tables read by their id or one of their classes, empty reads every table of the page
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// Columns locates the parts of a proxy in each row, only IP is required
+	Columns TableColumns `json:"columns" yaml:"columns"`
+	// Pages is the number of pages fetched for urls with a {page} placeholder, numbered from 1, it defaults to 1
+	Pages int `json:"pages,omitempty" yaml:"pages,omitempty"`
+	// NextLink is the text of the link to the next page, it is followed until MaxPages pages were read
+	NextLink string `json:"next_link,omitempty" yaml:"next_link,omitempty"`
+	MaxPages int    `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`
+}
+
+// TableColumns are the columns of a proxy table, columns with an empty Name are not read
+// The IP column may hold the port as well, the HTTPS column marks https proxies with yes, true, + or https, and the
+// Protocol column holds a protocol name such as socks5. The Country, Anonymity and HTTPS columns are only recorded as
+// declared attributes, see Proxy.Declare
+type TableColumns struct {
+	IP        TableColumn `json:"ip" yaml:"ip"`
+	Port      TableColumn `json:"port,omitempty" yaml:"port,omitempty"`
+	Country   TableColumn `json:"country,omitempty" yaml:"country,omitempty"`
+	Anonymity TableColumn `json:"anonymity,omitempty" yaml:"anonymity,omitempty"`
+	HTTPS     TableColumn `json:"https,omitempty" yaml:"https,omitempty"`
+	Protocol  TableColumn `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+}
+
+// TableColumn locates a column by its header text, matched case insensitively, or by its zero based position
+// Text written by inline scripts with document.write, including atob encoded strings, is read as part of the cell,
+// Decode names a decoder applied to the text afterwards, base64 or hex
+type TableColumn struct {
+	Name   string `json:"name" yaml:"name"`
+	Decode string `json:"decode,omitempty" yaml:"decode,omitempty"`
+}
+
+// cellDecoders are the decoders a TableColumn can name
+var cellDecoders = map[string]func(string) (string, error){
+	"base64": func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return string(b), err
+	},
+	"hex": func(s string) (string, error) {
+		b, err := hex.DecodeString(s)
+		return string(b), err
+	},
+}
+
+// validate checks that the columns can be read
+func (t TableOptions) validate() error {
+	if t.Columns.IP.Name == "" {
+		return fmt.Errorf("table has no ip column")
+	}
+	for _, column := range t.columns() {
+		if _, ok := cellDecoders[column.Decode]; column.Decode != "" && !ok {
+			return fmt.Errorf("unknown decoder %q", column.Decode)
+		}
+	}
+	return nil
+}
+
+// columns returns the columns in the order ip, port, country, anonymity, https, protocol
+func (t TableOptions) columns() []TableColumn {
+	c := t.Columns
+	return []TableColumn{c.IP, c.Port, c.Country, c.Anonymity, c.HTTPS, c.Protocol}
+}
+
+// parseTables returns the proxies in the tables of an html page, proxies without a protocol column speak protocol
+func (t TableOptions) parseTables(body string, protocol Protocol) ([]*Proxy, []*ParseError) {
+	var proxies []*Proxy
+	var rejected []*ParseError
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return proxies, []*ParseError{{Reason: err.Error()}}
+	}
+	for _, table := range findAll(doc, atom.Table) {
+		if t.Selector != "" && !matchesSelector(table, t.Selector) {
+			continue
+		}
+		var headers []string
+		for _, row := range findAll(table, atom.Tr) {
+			var cells []string
+			header := false
+			for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Th {
+					header = true
+				}
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					cells = append(cells, cellText(cell))
+				}
+			}
+			if header {
+				headers = cells
+				continue
+			}
+			values := make([]string, 0, 6)
+			for _, column := range t.columns() {
+				values = append(values, columnValue(column, headers, cells))
+			}
+			if values[0] == "" {
+				continue
+			}
+			https := strings.ToLower(values[4])
+			kind := values[5]
+			if kind == "" && (https == "yes" || https == "true" || https == "+" || https == "https") {
+				kind = string(HTTPS)
+			}
+			proxy, parseErr := sourceProxy(protocol, values[0], values[1], kind, "", "")
+			if parseErr != nil {
+				rejected = append(rejected, parseErr)
+				continue
+			}
+			proxy.Declare(AttrCountry, values[2])
+			proxy.Declare(AttrAnonymity, values[3])
+			proxy.Declare(AttrHTTPS, https)
+			proxies = append(proxies, proxy.listed(0, strings.Join(cells, " | ")))
+		}
+	}
+	return proxies, rejected
+}
+
+// nextPage returns the absolute url of the link whose text is t.NextLink, or an empty string when there is none
+func (t TableOptions) nextPage(body string, page string) string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(page)
+	if err != nil {
+		return ""
+	}
+	for _, link := range findAll(doc, atom.A) {
+		if !strings.EqualFold(cellText(link), strings.TrimSpace(t.NextLink)) {
+			continue
+		}
+		for _, attr := range link.Attr {
+			if attr.Key != "href" {
+				continue
+			}
+			next, err := base.Parse(attr.Val)
+			if err != nil || next.String() == page {
+				return ""
+			}
+			return next.String()
+		}
+	}
+	return ""
+}
+
+// columnValue returns the decoded text of column in a row, an empty string when the column is missing
+func columnValue(column TableColumn, headers []string, cells []string) string {
+	if column.Name == "" {
+		return ""
+	}
+	i, err := strconv.Atoi(column.Name)
+	if err != nil {
+		i = -1
+		for j, header := range headers {
+			if strings.EqualFold(header, strings.TrimSpace(column.Name)) {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 || i >= len(cells) {
+		return ""
+	}
+	value := cells[i]
+	if decode, ok := cellDecoders[column.Decode]; ok {
+		if decoded, err := decode(value); err == nil {
+			value = decoded
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// findAll returns the elements of type a below n, elements inside a match are not searched
+func findAll(n *html.Node, a atom.Atom) []*html.Node {
+	var found []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == a {
+			found = append(found, child)
+			continue
+		}
+		found = append(found, findAll(child, a)...)
+	}
+	return found
+}
+
+// matchesSelector returns whether the element has selector as its id or one of its classes
+func matchesSelector(n *html.Node, selector string) bool {
+	selector = strings.TrimLeft(selector, "#.")
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "id":
+			if attr.Val == selector {
+				return true
+			}
+		case "class":
+			for _, class := range strings.Fields(attr.Val) {
+				if class == selector {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// cellText returns the visible text of an element followed by the text its inline scripts write
+func cellText(n *html.Node) string {
+	var visible, scripted strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			visible.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Script:
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				scripted.WriteString(scriptText(child.Data))
+			}
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Style:
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(strings.TrimSpace(visible.String()) + scripted.String())
+}
+
+// scriptLiteral matches the string literals of a script, optionally wrapped in a base64 decoding call
+var scriptLiteral = regexp.MustCompile(`(atob|Base64\.decode)\(\s*(?:"([^"]*)"|'([^']*)')\s*\)|"([^"]*)"|'([^']*)'`)
+
+// scriptText returns the text written by a document.write script, the concatenation of its string literals with atob
+// calls decoded. Scripts which compute the text in other ways are not understood
+func scriptText(script string) string {
+	if i := strings.Index(script, "document.write("); i >= 0 {
+		script = script[i+len("document.write("):]
+	}
+	var text strings.Builder
+	for _, match := range scriptLiteral.FindAllStringSubmatch(script, -1) {
+		if match[1] != "" {
+			if decoded, err := base64.StdEncoding.DecodeString(match[2] + match[3]); err == nil {
+				text.Write(decoded)
+			}
+			continue
+		}
+		text.WriteString(match[4] + match[5])
+	}
+	return text.String()
+}
@@ -0,0 +1,130 @@
+package groxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceConfig_ProviderHTMLTable(t *testing.T) {
+	pages := map[string]string{
+		"/list": `<html><body>
+<table id="other"><tr><th>IP Address</th></tr><tr><td>9.9.9.9:80</td></tr></table>
+<table class="table proxies">
+<thead><tr><th>IP Address</th><th>Port</th><th>Code</th><th>Anonymity</th><th>Https</th></tr></thead>
+<tbody>
+<tr><td>1.2.3.4</td><td>ODA4MA==</td><td>us</td><td>elite proxy</td><td>yes</td></tr>
+<tr><td><script>document.write("5.6" + '.7.8')</script></td><td>MzEyOA==</td><td>de</td><td>anonymous</td><td>no</td></tr>
+<tr><td>n/a</td><td>ODA=</td><td></td><td></td><td></td></tr>
+</tbody></table>
+<a href="/list?page=2">Next</a>
+</body></html>`,
+		"/list?page=2": `<table class="proxies"><tr><th>IP Address</th><th>Port</th></tr>
+<tr><td><abbr><script>document.write(atob("MTAuMC4wLjE="))</script></abbr></td><td>ODA=</td></tr></table>
+<a href="/list">Next</a>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.RequestURI()])
+	}))
+	defer server.Close()
+
+	config := strings.Replace(`
+sources:
+  - name: table
+    urls: ["URL/list"]
+    format: html
+    table:
+      selector: proxies
+      next_link: Next
+      columns:
+        ip: {name: IP Address}
+        port: {name: port, decode: base64}
+        country: {name: Code}
+        anonymity: {name: "3"}
+        https: {name: Https}
+`, "URL", server.URL, -1)
+	configs, err := ParseSources([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseSources() error = %v", err)
+	}
+	provider, err := configs[0].Provider()
+	if err != nil {
+		t.Fatalf("Provider() error = %v", err)
+	}
+	resp := provider(context.Background())
+	if resp.Err != nil {
+		t.Fatalf("provider() error = %v", resp.Err)
+	}
+
+	var got []string
+	for _, proxy := range resp.Proxies {
+		declared := proxy.Declared()
+		got = append(got, fmt.Sprintf("%s %s %s %s %s %s %q", proxy.Protocol(), proxy.Host(), declared[AttrCountry],
+			declared[AttrAnonymity], declared[AttrHTTPS], proxy.Anonymity(), proxy.Country()))
+	}
+	// the country and anonymity listed are only declared until a check measures them
+	want := []string{
+		`https 1.2.3.4:8080 US elite true unknown ""`,
+		`http 5.6.7.8:3128 DE anonymous false unknown ""`,
+		`http 10.0.0.1:80    unknown ""`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("provider() = %q, want %q", got, want)
+	}
+	if len(resp.Rejected) != 1 || resp.Rejected[0].Input != "n/a:80" {
+		t.Errorf("provider() rejected = %v, want n/a:80", resp.Rejected)
+	}
+}
+
+func TestSourceConfig_ProviderHTMLTablePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<table><tr><th>ip</th></tr><tr><td>10.0.0.%s:80</td></tr></table>", r.URL.Query().Get("page"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		pages int
+		want  int
+	}{
+		{"pages default to one", 0, 1},
+		{"every page", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := SourceConfig{Name: "table", URLs: []string{server.URL + "/?page={page}"}, Format: FormatHTMLTable,
+				Table: TableOptions{Pages: tt.pages, Columns: TableColumns{IP: TableColumn{Name: "ip"}}}}
+			provider, err := config.Provider()
+			if err != nil {
+				t.Fatalf("Provider() error = %v", err)
+			}
+			if resp := provider(context.Background()); resp.Err != nil || len(resp.Proxies) != tt.want {
+				t.Errorf("provider() = %v proxies, %v, want %v", len(resp.Proxies), resp.Err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_scriptText(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"concatenation", `document.write("1.2" + '.3.4')`, "1.2.3.4"},
+		{"atob", `document.write(atob('MS4yLjMuNA=='))`, "1.2.3.4"},
+		{"mixed", `document.write(Base64.decode("MS4y") + ".3.4")`, "1.2.3.4"},
+		{"no literals", `document.write(x ^ y)`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptText(tt.script); got != tt.want {
+				t.Errorf("scriptText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
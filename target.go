@@ -0,0 +1,144 @@
+package groxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrBanned is returned by a TargetProfile check when the target served a page showing the proxy is banned
+var ErrBanned = errors.New("groxy: proxy banned by target")
+
+// TargetProfile describes how a site responds to a usable proxy, profiles are checked by a Manager configured with
+// WithTargets and the outcome is recorded on each proxy, see Proxy.WorksFor
+type TargetProfile struct {
+	// Name identifies the target in Proxy.WorksFor
+	Name string
+	// URL is the page requested through the proxy
+	URL string
+	// Statuses are the acceptable response statuses, 200 when empty
+	Statuses []int
+	// Marker is text the page contains when it was served normally, empty accepts any page
+	Marker string
+	// BanMarkers are texts, such as captcha, found on the pages served to banned proxies, they are matched case
+	// insensitively
+	BanMarkers []string
+}
+
+// TargetStatus is the usability of a proxy for a target the last time it was checked
+type TargetStatus struct {
+	Usable  bool
+	Banned  bool
+	Latency time.Duration
+	Checked time.Time
+}
+
+// Check requests the profile URL through proxy and returns nil when the response is usable, or a *CheckError of kind
+// FailureBanned wrapping ErrBanned when the page contains a ban marker
+func (t TargetProfile) Check(ctx context.Context, proxy *Proxy) error {
+	resp, body, err := proxyGet(ctx, proxy, t.URL)
+	if err != nil {
+		return requestFailure(t.URL, err)
+	}
+	page := strings.ToLower(string(body))
+	for _, marker := range t.BanMarkers {
+		if marker != "" && strings.Contains(page, strings.ToLower(marker)) {
+			return &CheckError{Kind: FailureBanned, Target: t.URL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("%w: %s served %q", ErrBanned, t.Name, marker)}
+		}
+	}
+	statuses := t.Statuses
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusOK}
+	}
+	accepted := false
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			return &CheckError{Kind: FailureBanned, Target: t.URL, StatusCode: resp.StatusCode,
+				Err: fmt.Errorf("%w: %s answered %s", ErrBanned, t.Name, resp.Status)}
+		}
+		return statusFailure(t.URL, resp.StatusCode)
+	}
+	if t.Marker != "" && !strings.Contains(string(body), t.Marker) {
+		return &CheckError{Kind: FailureContentMismatch, Target: t.URL, StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("page does not contain %q", t.Marker)}
+	}
+	return nil
+}
+
+// targetChecker adapts a TargetProfile to the Checker interface
+type targetChecker struct {
+	TargetProfile
+}
+
+func (t targetChecker) Name() string {
+	return t.TargetProfile.Name
+}
+
+// targetCheckers returns a checker for each profile
+func targetCheckers(profiles []TargetProfile) []Checker {
+	checkers := make([]Checker, 0, len(profiles))
+	for _, profile := range profiles {
+		checkers = append(checkers, targetChecker{profile})
+	}
+	return checkers
+}
+
+// WorksFor returns whether the proxy was usable for the named target the last time it was checked
+func (h *Proxy) WorksFor(target string) bool {
+	status, ok := h.Target(target)
+	return ok && status.Usable
+}
+
+// Target returns the status of the proxy for the named target, ok is false when it was never checked against it
+func (h *Proxy) Target(target string) (status TargetStatus, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok = h.targets[target]
+	return status, ok
+}
+
+// Targets returns the status of the proxy for every target it was checked against
+func (h *Proxy) Targets() map[string]TargetStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	targets := make(map[string]TargetStatus, len(h.targets))
+	for name, status := range h.targets {
+		targets[name] = status
+	}
+	return targets
+}
+
+// setTarget records the outcome of checking the proxy against a target
+func (h *Proxy) setTarget(outcome CheckOutcome, checked time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.targets == nil {
+		h.targets = make(map[string]TargetStatus)
+	}
+	h.targets[outcome.Name] = TargetStatus{
+		Usable:  outcome.Passed,
+		Banned:  errors.Is(outcome.Err, ErrBanned),
+		Latency: outcome.Duration,
+		Checked: checked,
+	}
+}
+
+// worksForAll returns whether proxy is usable for every one of targets
+func worksForAll(proxy *Proxy, targets []string) bool {
+	for _, target := range targets {
+		if !proxy.WorksFor(target) {
+			return false
+		}
+	}
+	return true
+}
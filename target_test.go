@@ -0,0 +1,96 @@
+package groxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetProfile_Check(t *testing.T) {
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/captcha":
+			fmt.Fprint(w, "Please solve this CAPTCHA")
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, `<div id="results">`)
+		}
+	}))
+	defer site.Close()
+
+	tests := []struct {
+		name       string
+		profile    TargetProfile
+		wantErr    bool
+		wantBanned bool
+	}{
+		{"usable", TargetProfile{URL: site.URL, Marker: `id="results"`}, false, false},
+		{"marker missing", TargetProfile{URL: site.URL, Marker: "welcome"}, true, false},
+		{"ban marker", TargetProfile{URL: site.URL + "/captcha", BanMarkers: []string{"captcha"}}, true, true},
+		{"forbidden", TargetProfile{URL: site.URL + "/forbidden"}, true, true},
+		{"server error", TargetProfile{URL: site.URL + "/error"}, true, false},
+		{"accepted status", TargetProfile{URL: site.URL + "/error", Statuses: []int{500}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := tt.profile.Check(ctx, proxy)
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrBanned) != tt.wantBanned {
+				t.Errorf("Check() error = %v, wantErr %v wantBanned %v", err, tt.wantErr, tt.wantBanned)
+			}
+		})
+	}
+}
+
+func TestManager_WithTargets(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search" {
+			fmt.Fprint(w, "unusual traffic, captcha")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer site.Close()
+
+	manager := NewManager(1, 5*time.Second, "", WithSelfHostedJudge(judge.URL), WithTargets(
+		TargetProfile{Name: "shop", URL: site.URL + "/shop"},
+		TargetProfile{Name: "search", URL: site.URL + "/search", BanMarkers: []string{"captcha"}},
+	))
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	manager.Add(proxy)
+	for result := range manager.Run(context.Background()) {
+		if result.Err != nil || len(result.Targets) != 2 {
+			t.Errorf("Run() result = %v targets %+v", result.Err, result.Targets)
+		}
+	}
+	if !proxy.Alive() || !proxy.WorksFor("shop") || proxy.WorksFor("search") || proxy.WorksFor("unknown") {
+		t.Errorf("Targets() = %+v, want alive and usable for shop only", proxy.Targets())
+	}
+	if status, _ := proxy.Target("search"); !status.Banned {
+		t.Errorf("Target(search) = %+v, want banned", status)
+	}
+
+	pool := NewPool(nil, New("1.2.3.4:80", "", ""), proxy)
+	if got, err := pool.NextFor("shop"); err != nil || got != proxy {
+		t.Errorf("NextFor(shop) = %v, %v, want %v", got, err, proxy)
+	}
+	if _, err := pool.NextFor("shop", "search"); err != ErrPoolEmpty {
+		t.Errorf("NextFor(shop, search) error = %v, want %v", err, ErrPoolEmpty)
+	}
+}
@@ -0,0 +1,158 @@
+package groxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phase is a phase of a request made through a proxy
+type Phase int
+
+const (
+	// PhaseConnect is the tcp connection to the proxy
+	PhaseConnect Phase = iota
+	// PhaseHandshake is the proxy handshake, the CONNECT request of http proxies or the negotiation of socks proxies
+	PhaseHandshake
+	// PhaseTLS is the tls handshake with the target, it is zero for plain http targets
+	PhaseTLS
+	// PhaseFirstByte is the time between sending the request and receiving the first byte of the response
+	PhaseFirstByte
+	// PhaseTotal is the time until the response headers were received, it is the proxy's ResponseTime
+	PhaseTotal
+)
+
+var phaseNames = []string{"connect", "handshake", "tls", "first-byte", "total"}
+
+// String returns the name of the phase
+func (p Phase) String() string {
+	if p < 0 || int(p) >= len(phaseNames) {
+		return "unknown"
+	}
+	return phaseNames[p]
+}
+
+// ParsePhase returns the phase named by s, one of connect, handshake, tls, first-byte or total
+func ParsePhase(s string) (Phase, error) {
+	for i, name := range phaseNames {
+		if strings.EqualFold(s, name) {
+			return Phase(i), nil
+		}
+	}
+	return 0, fmt.Errorf("groxy: unknown phase %q", s)
+}
+
+// Timing is the duration of each phase of a request made through a proxy
+type Timing struct {
+	Connect   time.Duration
+	Handshake time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// Phase returns the duration of phase
+func (t Timing) Phase(phase Phase) time.Duration {
+	switch phase {
+	case PhaseConnect:
+		return t.Connect
+	case PhaseHandshake:
+		return t.Handshake
+	case PhaseTLS:
+		return t.TLS
+	case PhaseFirstByte:
+		return t.FirstByte
+	case PhaseTotal:
+		return t.Total
+	}
+	return 0
+}
+
+// within returns whether every phase of t is no longer than the same phase of max, zero phases of max are not compared
+func (t Timing) within(max Timing) bool {
+	for phase := range phaseNames {
+		limit := max.Phase(Phase(phase))
+		if limit > 0 && t.Phase(Phase(phase)) > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// SortByPhase sorts proxies by the duration of phase in their last successful check, fastest first, proxies which were
+// never timed go last
+func SortByPhase(proxies []*Proxy, phase Phase) {
+	sort.SliceStable(proxies, func(i, j int) bool {
+		a, b := proxies[i].Timing(), proxies[j].Timing()
+		if a.Total == 0 || b.Total == 0 {
+			return a.Total != 0
+		}
+		return a.Phase(phase) < b.Phase(phase)
+	})
+}
+
+// tracer collects the times of the events of a request traced with httptrace
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+}
+
+// newTracer returns a tracer of a request starting now
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+// trace returns the hooks recording the events, the connection to the proxy is the first one started and the last one
+// done when several addresses are tried
+func (tr *tracer) trace() *httptrace.ClientTrace {
+	record := func(t *time.Time, keepFirst bool) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		if keepFirst && !t.IsZero() {
+			return
+		}
+		*t = time.Now()
+	}
+	return &httptrace.ClientTrace{
+		ConnectStart:         func(network, addr string) { record(&tr.connectStart, true) },
+		ConnectDone:          func(network, addr string, err error) { record(&tr.connectDone, false) },
+		TLSHandshakeStart:    func() { record(&tr.tlsStart, true) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&tr.tlsDone, false) },
+		GotConn:              func(httptrace.GotConnInfo) { record(&tr.gotConn, false) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { record(&tr.wroteRequest, false) },
+		GotFirstResponseByte: func() { record(&tr.firstByte, true) },
+	}
+}
+
+// timing returns the duration of each phase of a request whose response headers were received at end
+func (tr *tracer) timing(end time.Time) Timing {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	t := Timing{
+		Connect:   between(tr.connectStart, tr.connectDone),
+		TLS:       between(tr.tlsStart, tr.tlsDone),
+		FirstByte: between(tr.wroteRequest, tr.firstByte),
+		Total:     between(tr.start, end),
+	}
+	if handshake := between(tr.connectDone, tr.gotConn) - t.TLS; handshake > 0 {
+		t.Handshake = handshake
+	}
+	return t
+}
@@ -0,0 +1,92 @@
+package groxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracer(t *testing.T) {
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+
+	transport := newTransport(proxy)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	tracer := newTracer()
+	req, _ := http.NewRequest("GET", secure.URL, nil)
+	resp, err := transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(context.Background(), tracer.trace())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	timing := tracer.timing(time.Now())
+	for phase := PhaseConnect; phase <= PhaseTotal; phase++ {
+		if timing.Phase(phase) <= 0 {
+			t.Errorf("timing() %v = %v, want > 0", phase, timing.Phase(phase))
+		}
+	}
+	if sum := timing.Connect + timing.Handshake + timing.TLS + timing.FirstByte; sum > timing.Total {
+		t.Errorf("timing() phases add up to %v, more than total %v", sum, timing.Total)
+	}
+}
+
+func TestManager_timing(t *testing.T) {
+	judge := httptest.NewServer(NewJudge())
+	defer judge.Close()
+	upstream := upstreamProxy(t)
+	defer upstream.Close()
+
+	manager := NewManager(1, 5*time.Second, "", WithSelfHostedJudge(judge.URL))
+	proxy := New(strings.TrimPrefix(upstream.URL, "http://"), "", "")
+	manager.Add(proxy)
+	for result := range manager.Run(context.Background()) {
+		if result.Err != nil || result.Timing.Connect <= 0 || result.Timing.FirstByte <= 0 || result.Timing.TLS != 0 {
+			t.Errorf("Run() result = %v timing %+v", result.Err, result.Timing)
+		}
+	}
+	if proxy.Timing().Total != proxy.ResponseTime() || proxy.ResponseTime() <= 0 {
+		t.Errorf("Timing() = %+v, want total %v", proxy.Timing(), proxy.ResponseTime())
+	}
+}
+
+func TestSortByPhase(t *testing.T) {
+	untimed := New("1.1.1.1:80", "", "")
+	slowConnect := New("2.2.2.2:80", "", "")
+	slowConnect.setAlive(Timing{Connect: 300 * time.Millisecond, FirstByte: 10 * time.Millisecond, Total: time.Second}, Elite)
+	slowServer := New("3.3.3.3:80", "", "")
+	slowServer.setAlive(Timing{Connect: 10 * time.Millisecond, FirstByte: 900 * time.Millisecond, Total: time.Second}, Elite)
+
+	tests := []struct {
+		phase Phase
+		want  []*Proxy
+	}{
+		{PhaseConnect, []*Proxy{slowServer, slowConnect, untimed}},
+		{PhaseFirstByte, []*Proxy{slowConnect, slowServer, untimed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phase.String(), func(t *testing.T) {
+			proxies := []*Proxy{untimed, slowConnect, slowServer}
+			SortByPhase(proxies, tt.phase)
+			if !reflect.DeepEqual(proxies, tt.want) {
+				t.Errorf("SortByPhase() = %v, want %v", proxies, tt.want)
+			}
+		})
+	}
+
+	filter := Filter{MaxTiming: Timing{Connect: 100 * time.Millisecond}}
+	if got := filter.Apply([]*Proxy{slowConnect, slowServer}); !reflect.DeepEqual(got, []*Proxy{slowServer}) {
+		t.Errorf("Filter.Apply() = %v, want %v", got, []*Proxy{slowServer})
+	}
+	if phase, err := ParsePhase("First-Byte"); err != nil || phase != PhaseFirstByte {
+		t.Errorf("ParsePhase() = %v, %v, want %v", phase, err, PhaseFirstByte)
+	}
+}